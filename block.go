@@ -0,0 +1,237 @@
+package vprompt
+
+import (
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// blockRect is a rectangular region of the input spanning the rows top to
+// bottom (inclusive) and the rune columns left to right (exclusive). A block
+// with left equal to right has zero width and acts as a column of cursors.
+type blockRect struct {
+	top    int
+	bottom int
+	left   int
+	right  int
+}
+
+// toggleBlockMode starts a block selection anchored at the cursor or ends the
+// current one.
+func (m *PromptModel) toggleBlockMode() {
+	if m.blockMode {
+		m.blockMode = false
+		return
+	}
+
+	m.blockMode = true
+	m.blockAnchor = cursor{row: m.cursorRow, col: m.cursorCol}
+	m.clearAutocomplete()
+}
+
+// handleBlockKey handles a key press while block selection mode is active.
+// Arrow keys extend the block, typed characters replace its content on every
+// selected line and Backspace/Delete remove it. Ctrl+B and Esc end block mode.
+// Any other key ends block mode and is left to the regular key handling. It
+// reports whether the key was handled.
+func (m *PromptModel) handleBlockKey(msg tea.KeyMsg) bool {
+	switch msg.Type {
+	case tea.KeyUp:
+		m.moveCursorUp()
+
+	case tea.KeyDown:
+		m.moveCursorDown()
+
+	case tea.KeyLeft:
+		// Stay within the current line.
+		m.cursorCol = max(0, m.cursorCol-1)
+
+	case tea.KeyRight:
+		// Stay within the current line.
+		m.cursorCol = min(
+			m.lineLen(m.cursorRow), m.cursorCol+1,
+		)
+
+	case tea.KeyRunes:
+		m.insertBlock(msg.Runes)
+
+	case tea.KeySpace:
+		m.insertBlock([]rune{' '})
+
+	case tea.KeyBackspace:
+		m.deleteBlock(true)
+
+	case tea.KeyDelete:
+		m.deleteBlock(false)
+
+	case tea.KeyCtrlB, tea.KeyEsc:
+		m.blockMode = false
+
+	default:
+		// Leave block mode for all other keys.
+		m.blockMode = false
+		return false
+	}
+
+	return true
+}
+
+// block returns the rectangle spanned by the block anchor and the cursor. Its
+// rows are clamped to the input, so that a stale anchor never refers to lines
+// that no longer exist.
+func (m PromptModel) block() blockRect {
+	lastRow := len(m.lines) - 1
+
+	return blockRect{
+		top:    min(m.blockAnchor.row, m.cursorRow, lastRow),
+		bottom: min(max(m.blockAnchor.row, m.cursorRow), lastRow),
+		left:   min(m.blockAnchor.col, m.cursorCol),
+		right:  max(m.blockAnchor.col, m.cursorCol),
+	}
+}
+
+// resetSelection ends block mode and drops all additional cursors, e.g., when
+// the input they refer to is replaced.
+func (m *PromptModel) resetSelection() {
+	m.blockMode = false
+	m.blockAnchor = cursor{}
+	m.extraCursors = nil
+}
+
+// inBlock reports whether block mode is active and the rune at the given
+// position is part of the selected block.
+func (m PromptModel) inBlock(row, col int) bool {
+	if !m.blockMode {
+		return false
+	}
+
+	b := m.block()
+	return row >= b.top && row <= b.bottom && col >= b.left &&
+		col < b.right
+}
+
+// collapseBlock turns the block into a zero-width column at the given column,
+// keeping its rows.
+func (m *PromptModel) collapseBlock(col int) {
+	m.blockAnchor.col = col
+	m.cursorCol = col
+}
+
+// removeBlockContent removes the runes covered by the block from every
+// selected line and collapses the block to its left column.
+func (m *PromptModel) removeBlockContent() {
+	b := m.block()
+	for row := b.top; row <= b.bottom; row++ {
+		runes := []rune(m.lines[row])
+
+		// Lines may end before or within the block.
+		left := min(b.left, len(runes))
+		right := min(b.right, len(runes))
+		m.markEdited()
+		m.lines[row] = string(runes[:left]) + string(runes[right:])
+	}
+
+	m.collapseBlock(b.left)
+}
+
+// insertBlock replaces the content of the block with the given runes on every
+// selected line. Lines that end before the block's left column are left
+// unchanged. Afterwards, the block is a zero-width column right after the
+// inserted text, so that typing continues on all lines.
+func (m *PromptModel) insertBlock(runes []rune) {
+	// Handle control characters and casing like regular insertion.
+	insert := m.filterControlChars(runes)
+	if len(insert) == 0 {
+		return
+	}
+	m.applyCaseTransform(insert)
+
+	// Typed text replaces the selected content.
+	m.removeBlockContent()
+
+	b := m.block()
+	for row := b.top; row <= b.bottom; row++ {
+		lineRunes := []rune(m.lines[row])
+		if len(lineRunes) < b.left {
+			continue
+		}
+
+		m.markEdited()
+		m.lines[row] = string(lineRunes[:b.left]) + string(insert) +
+			string(lineRunes[b.left:])
+	}
+
+	m.collapseBlock(b.left + len(insert))
+}
+
+// deleteBlock removes the content of the block from every selected line. If
+// the block has zero width, the rune before (backward) or after the block
+// column is deleted on every line instead. Lines are never merged.
+func (m *PromptModel) deleteBlock(backward bool) {
+	b := m.block()
+	if b.left < b.right {
+		m.removeBlockContent()
+		return
+	}
+
+	// Determine the column of the rune to delete on every line.
+	col := b.left
+	if backward {
+		if col == 0 {
+			return
+		}
+		col--
+	}
+
+	for row := b.top; row <= b.bottom; row++ {
+		runes := []rune(m.lines[row])
+		if col >= len(runes) {
+			continue
+		}
+
+		m.markEdited()
+		m.lines[row] = string(runes[:col]) + string(runes[col+1:])
+	}
+
+	m.collapseBlock(col)
+}
+
+// selectedText returns the content of the block selection, with the selected
+// part of every line joined by newlines. It returns an empty string if block
+// mode isn't active or the block has zero width.
+func (m PromptModel) selectedText() string {
+	if !m.blockMode {
+		return ""
+	}
+
+	b := m.block()
+	if b.left == b.right {
+		return ""
+	}
+
+	selected := make([]string, 0, b.bottom-b.top+1)
+	for row := b.top; row <= b.bottom; row++ {
+		runes := []rune(m.lines[row])
+
+		// Lines may end before or within the block.
+		left := min(b.left, len(runes))
+		right := min(b.right, len(runes))
+		selected = append(selected, string(runes[left:right]))
+	}
+
+	return strings.Join(selected, "\n")
+}
+
+// SubmitSelection executes only the text of the current block selection, e.g.,
+// to run a part of a script. Like Submit, the text is transformed by the
+// configured PreExecuteFn, but the input is left unchanged and the selected
+// text isn't stored in the history. It returns the command of an asynchronous
+// execution, if any. Nothing is executed if the selection is empty.
+func (m *PromptModel) SubmitSelection() tea.Cmd {
+	text := m.selectedText()
+	if strings.TrimSpace(text) == "" {
+		return nil
+	}
+
+	return m.execute(m.transformInput(text))
+}
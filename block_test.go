@@ -0,0 +1,137 @@
+package vprompt
+
+import (
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// TestBlockInsertPrefix tests inserting a prefix across three lines with a
+// zero-width block.
+func TestBlockInsertPrefix(t *testing.T) {
+	m := NewPromptModel(PromptConfig{})
+	typeText(m, "a\nbb\nccc")
+	pressKeys(m, tea.KeyCtrlHome, tea.KeyCtrlB, tea.KeyDown, tea.KeyDown)
+	if !m.blockMode {
+		t.Fatalf("expected block mode")
+	}
+
+	typeText(m, "-- ")
+	if got := m.getCurrentInput(); got != "-- a\n-- bb\n-- ccc" {
+		t.Fatalf("expected prefix on every line, got %q", got)
+	}
+
+	// Typing continues on all lines until block mode ends.
+	pressKeys(m, tea.KeyCtrlB)
+	typeText(m, "x")
+	if got := m.getCurrentInput(); got != "-- a\n-- bb\n-- xccc" {
+		t.Fatalf("expected typing at the cursor only, got %q", got)
+	}
+}
+
+// TestBlockReplaceAndDelete tests replacing and deleting the content of a
+// rectangular block, including a line ending at the block.
+func TestBlockReplaceAndDelete(t *testing.T) {
+	m := NewPromptModel(PromptConfig{})
+	typeText(m, "abcd\na\nabcd")
+	pressKeys(m, tea.KeyCtrlHome, tea.KeyRight, tea.KeyCtrlB,
+		tea.KeyDown, tea.KeyDown, tea.KeyRight, tea.KeyRight)
+
+	typeText(m, "X")
+	if got := m.getCurrentInput(); got != "aXd\naX\naXd" {
+		t.Fatalf("expected block content replaced, got %q", got)
+	}
+
+	pressKeys(m, tea.KeyBackspace)
+	if got := m.getCurrentInput(); got != "ad\na\nad" {
+		t.Fatalf("expected Backspace to delete before the block, "+
+			"got %q", got)
+	}
+}
+
+// TestBlockResetOnInputReset tests that replacing the input ends block mode,
+// so that typing afterwards doesn't refer to lines that no longer exist, and
+// that a stale block is clamped to the input.
+func TestBlockResetOnInputReset(t *testing.T) {
+	resets := map[string]func(m *PromptModel){
+		"submit": func(m *PromptModel) { m.Submit() },
+		"stash":  func(m *PromptModel) { m.Stash() },
+	}
+
+	for name, reset := range resets {
+		m := NewPromptModel(PromptConfig{
+			IsCompleteFn: func(string) bool { return true },
+		})
+		typeText(m, "ab")
+		m.SetLine(0, "ab\ncd")
+		pressKeys(m, tea.KeyCtrlB, tea.KeyUp)
+
+		reset(m)
+		if m.blockMode || len(m.extraCursors) != 0 {
+			t.Fatalf("%s: expected selection to end", name)
+		}
+
+		typeText(m, "x")
+		if got := m.getCurrentInput(); got != "x" {
+			t.Fatalf("%s: expected %q, got %q", name, "x", got)
+		}
+	}
+
+	// A block whose anchor is below the last line only covers the
+	// existing lines.
+	m := NewPromptModel(PromptConfig{})
+	typeText(m, "ab")
+	m.blockMode = true
+	m.blockAnchor = cursor{row: 3, col: 2}
+	typeText(m, "x")
+	if got := m.getCurrentInput(); got != "abx" {
+		t.Fatalf("expected clamped block insertion, got %q", got)
+	}
+}
+
+// TestSubmitSelection tests that only the selected statement is executed,
+// transformed by PreExecuteFn, leaving the input and the history unchanged,
+// and that nothing is executed without a selection.
+func TestSubmitSelection(t *testing.T) {
+	var executed []string
+	m := NewPromptModel(PromptConfig{
+		ExecuteFn: func(input string) string {
+			executed = append(executed, input)
+			return ""
+		},
+		PreExecuteFn: strings.ToLower,
+	})
+	typeText(m, "SELECT 1; SELECT 2;")
+
+	// Nothing is selected outside of block mode or with a zero-width
+	// block.
+	m.SubmitSelection()
+	pressKeys(m, tea.KeyCtrlB)
+	m.SubmitSelection()
+	if len(executed) != 0 {
+		t.Fatalf("expected nothing executed, got %q", executed)
+	}
+
+	// Select the second statement.
+	pressKeys(m, tea.KeyCtrlB, tea.KeyCtrlHome)
+	for i := 0; i < 10; i++ {
+		pressKeys(m, tea.KeyRight)
+	}
+	pressKeys(m, tea.KeyCtrlB)
+	for i := 0; i < 9; i++ {
+		pressKeys(m, tea.KeyRight)
+	}
+
+	m.SubmitSelection()
+	if len(executed) != 1 || executed[0] != "select 2;" {
+		t.Fatalf("expected the transformed selection executed, got %q",
+			executed)
+	}
+	if got := m.getCurrentInput(); got != "SELECT 1; SELECT 2;" {
+		t.Fatalf("expected input to be unchanged, got %q", got)
+	}
+	if m.history.Len() != 0 {
+		t.Fatalf("expected the selection not to be stored in history")
+	}
+}
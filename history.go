@@ -0,0 +1,84 @@
+package vprompt
+
+// HistoryStore defines the interface for storing previously executed commands.
+// It allows history to be kept outside of memory, e.g., in a database. Entries
+// are indexed from zero (oldest) to Len()-1 (most recent).
+type HistoryStore interface {
+	// Append adds a new entry as the most recent history item.
+	Append(entry string)
+
+	// At returns the history entry at the given index.
+	At(i int) string
+
+	// Len returns the number of entries in the history.
+	Len() int
+}
+
+// HistoryReplacer is an optional interface a HistoryStore can implement to
+// allow replacing all of its entries at once, e.g., when restoring a snapshot
+// of the prompt.
+type HistoryReplacer interface {
+	// Replace replaces all entries with the given ones, oldest first.
+	Replace(entries []string)
+}
+
+// sliceHistory is the default in-memory HistoryStore implementation backed by a
+// string slice.
+type sliceHistory struct {
+	// entries holds the stored commands, oldest first.
+	entries []string
+}
+
+// newSliceHistory creates a new, empty in-memory history store.
+func newSliceHistory() *sliceHistory {
+	return &sliceHistory{
+		entries: []string{},
+	}
+}
+
+// Append adds a new entry as the most recent history item.
+//
+// NOTE: This is part of the HistoryStore interface.
+func (h *sliceHistory) Append(entry string) {
+	h.entries = append(h.entries, entry)
+}
+
+// At returns the history entry at the given index.
+//
+// NOTE: This is part of the HistoryStore interface.
+func (h *sliceHistory) At(i int) string {
+	return h.entries[i]
+}
+
+// Len returns the number of entries in the history.
+//
+// NOTE: This is part of the HistoryStore interface.
+func (h *sliceHistory) Len() int {
+	return len(h.entries)
+}
+
+// Replace replaces all entries with the given ones, oldest first.
+//
+// NOTE: This is part of the HistoryReplacer interface.
+func (h *sliceHistory) Replace(entries []string) {
+	h.entries = append([]string{}, entries...)
+}
+
+// replaceHistory replaces all history entries with the given ones, oldest
+// first. Stores implementing HistoryReplacer are replaced in place. Other
+// stores can't be truncated, so they are swapped for an in-memory store that
+// also becomes the configured History. Any in-progress history navigation
+// ends.
+func (m *PromptModel) replaceHistory(entries []string) {
+	if replacer, ok := m.history.(HistoryReplacer); ok {
+		replacer.Replace(entries)
+	} else {
+		history := newSliceHistory()
+		history.Replace(entries)
+
+		m.history = history
+		m.config.History = history
+	}
+
+	m.historyIndex = -1
+}
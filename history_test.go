@@ -0,0 +1,335 @@
+package vprompt
+
+import (
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// fakeHistory is a HistoryStore for tests that counts the calls made to it.
+type fakeHistory struct {
+	entries []string
+	appends int
+	reads   int
+}
+
+// Append adds a new entry as the most recent history item.
+//
+// NOTE: This is part of the HistoryStore interface.
+func (h *fakeHistory) Append(entry string) {
+	h.appends++
+	h.entries = append(h.entries, entry)
+}
+
+// At returns the history entry at the given index.
+//
+// NOTE: This is part of the HistoryStore interface.
+func (h *fakeHistory) At(i int) string {
+	h.reads++
+	return h.entries[i]
+}
+
+// Len returns the number of entries in the history.
+//
+// NOTE: This is part of the HistoryStore interface.
+func (h *fakeHistory) Len() int {
+	return len(h.entries)
+}
+
+// appendHistory appends the given entries to the history of the model, oldest
+// first.
+func appendHistory(m *PromptModel, entries ...string) {
+	for _, entry := range entries {
+		m.history.Append(entry)
+	}
+}
+
+// TestHistoryStore asserts that submitted commands are appended to a
+// configured HistoryStore and that history navigation reads from it.
+func TestHistoryStore(t *testing.T) {
+	store := &fakeHistory{entries: []string{"SELECT 1;"}}
+	m := NewPromptModel(PromptConfig{History: store})
+
+	typeText(m, "SELECT 2;\n")
+	if store.appends != 1 || store.entries[1] != "SELECT 2;" {
+		t.Fatalf("expected submit to append, got %q", store.entries)
+	}
+
+	// Up recalls the most recent entry first, then older ones.
+	pressKeys(m, tea.KeyUp)
+	if m.getCurrentInput() != "SELECT 2;" {
+		t.Fatalf("expected most recent entry, got %q",
+			m.getCurrentInput())
+	}
+	pressKeys(m, tea.KeyUp)
+	if m.getCurrentInput() != "SELECT 1;" {
+		t.Fatalf("expected oldest entry, got %q", m.getCurrentInput())
+	}
+
+	// Up at the oldest entry stays there.
+	pressKeys(m, tea.KeyUp)
+	if m.getCurrentInput() != "SELECT 1;" {
+		t.Fatalf("expected to stay at oldest entry, got %q",
+			m.getCurrentInput())
+	}
+
+	// Down moves forward again and past the most recent entry clears
+	// the input.
+	pressKeys(m, tea.KeyDown)
+	if m.getCurrentInput() != "SELECT 2;" {
+		t.Fatalf("expected most recent entry, got %q",
+			m.getCurrentInput())
+	}
+	pressKeys(m, tea.KeyDown)
+	if m.getCurrentInput() != "" || m.historyIndex != -1 {
+		t.Fatalf("expected empty input after history, got %q",
+			m.getCurrentInput())
+	}
+}
+
+// TestHistoryDefaultStore asserts that an in-memory store is used if none is
+// configured.
+func TestHistoryDefaultStore(t *testing.T) {
+	m := NewPromptModel(PromptConfig{})
+
+	typeText(m, "SELECT 1;\n")
+	if m.history.Len() != 1 || m.history.At(0) != "SELECT 1;" {
+		t.Fatalf("expected entry in default store")
+	}
+}
+
+// TestOnHistoryNavigate asserts that the OnHistoryNavigate callback fires with
+// the recalled entry and its index.
+func TestOnHistoryNavigate(t *testing.T) {
+	var (
+		entries []string
+		indexes []int
+	)
+	m := NewPromptModel(PromptConfig{
+		OnHistoryNavigate: func(entry string, index int) {
+			entries = append(entries, entry)
+			indexes = append(indexes, index)
+		},
+	})
+	typeText(m, "SELECT 1;\nSELECT 2;\n")
+
+	pressKeys(m, tea.KeyUp, tea.KeyUp)
+	if len(entries) != 2 || entries[0] != "SELECT 2;" ||
+		entries[1] != "SELECT 1;" || indexes[0] != 1 ||
+		indexes[1] != 0 {
+
+		t.Fatalf("unexpected callbacks: %q %v", entries, indexes)
+	}
+}
+
+// TestHistoryMatching tests that HistoryMatching returns the entries starting
+// with a prefix, most recent first.
+func TestHistoryMatching(t *testing.T) {
+	m := NewPromptModel(PromptConfig{})
+	for _, entry := range []string{
+		"SELECT 1;", "UPDATE t;", "SELECT 2;", "select 3;",
+		"SELECT 4;",
+	} {
+		m.history.Append(entry)
+	}
+
+	got := strings.Join(m.HistoryMatching("SELECT"), "|")
+	if got != "SELECT 4;|SELECT 2;|SELECT 1;" {
+		t.Fatalf("unexpected matches: %q", got)
+	}
+
+	if got := m.HistoryMatching("DELETE"); len(got) != 0 {
+		t.Fatalf("expected no matches, got %q", got)
+	}
+	if got := m.HistoryMatching(""); len(got) != 5 {
+		t.Fatalf("expected all entries for an empty prefix, got %q",
+			got)
+	}
+}
+
+// TestHistoryCursorPlacement tests that recalled history entries place the
+// cursor at their end by default or at their start if HistoryCursorAtStart is
+// set, always within the loaded content.
+func TestHistoryCursorPlacement(t *testing.T) {
+	type step struct {
+		input string
+		row   int
+		col   int
+	}
+
+	tests := []struct {
+		atStart bool
+		// steps holds the expected state after each Up.
+		steps []step
+	}{{
+		atStart: false,
+		steps: []step{
+			{"a;\nbcde", 1, 4},
+			// Up first moves within the multi-line entry.
+			{"a;\nbcde", 0, 2},
+			{"abcd", 0, 4},
+			{"SELECT 1;", 0, 9},
+		},
+	}, {
+		atStart: true,
+		steps: []step{
+			{"a;\nbcde", 0, 0},
+			{"abcd", 0, 0},
+			{"SELECT 1;", 0, 0},
+		},
+	}}
+
+	for _, test := range tests {
+		m := NewPromptModel(PromptConfig{
+			HistoryCursorAtStart: test.atStart,
+		})
+		appendHistory(m, "SELECT 1;", "abcd", "a;\nbcde")
+
+		for i, want := range test.steps {
+			pressKeys(m, tea.KeyUp)
+			got := step{m.getCurrentInput(), m.cursorRow,
+				m.cursorCol}
+			if got != want {
+				t.Fatalf("start %v, step %d: expected %v, "+
+					"got %v", test.atStart, i, want, got)
+			}
+		}
+	}
+}
+
+// TestSnapshotRestore asserts that restoring a snapshot reproduces the
+// editing state exactly, replacing a longer history in place, and that the
+// snapshot is not affected by later edits.
+func TestSnapshotRestore(t *testing.T) {
+	m := NewPromptModel(PromptConfig{})
+	typeText(m, "SELECT 1;\nSELECT 2;\nSELECT *\nFROM t")
+	pressKeys(m, tea.KeyLeft)
+	m.Update(OutputMsg("two rows"))
+	state := m.Snapshot()
+	if state.Output == "" {
+		t.Fatalf("expected the output in the snapshot")
+	}
+
+	// Edits after the snapshot don't change it.
+	typeText(m, "x")
+	if strings.Join(state.Lines, "\n") != "SELECT *\nFROM t" {
+		t.Fatalf("expected snapshot to be unchanged, got %q",
+			state.Lines)
+	}
+
+	store := newSliceHistory()
+	other := NewPromptModel(PromptConfig{History: store})
+	typeText(other, "a;\nb;\nc;\nd;\n")
+	other.Restore(state)
+
+	if other.history != store {
+		t.Fatalf("expected the configured store to be kept")
+	}
+	got := other.Snapshot()
+	if strings.Join(got.Lines, "\n") != "SELECT *\nFROM t" ||
+		got.CursorRow != 1 || got.CursorCol != 5 ||
+		strings.Join(got.History, ",") != "SELECT 1;,SELECT 2;" ||
+		got.Output != state.Output {
+
+		t.Fatalf("expected restored state %+v, got %+v", state, got)
+	}
+}
+
+// TestRestoreSwapsPlainStore asserts that restoring into a store that can't
+// be replaced swaps in an in-memory store with the snapshot's history.
+func TestRestoreSwapsPlainStore(t *testing.T) {
+	store := &fakeHistory{entries: []string{"a;", "b;", "c;"}}
+	m := NewPromptModel(PromptConfig{History: store})
+	m.Restore(State{History: []string{"x;"}})
+
+	if m.history.Len() != 1 || m.history.At(0) != "x;" {
+		t.Fatalf("expected restored history, got %d entries",
+			m.history.Len())
+	}
+	if m.config.History != m.history {
+		t.Fatalf("expected the configured history to be updated")
+	}
+	if len(store.entries) != 3 {
+		t.Fatalf("expected the previous store to be untouched")
+	}
+}
+
+// TestHistoryEnterSubmits asserts that Enter on a recalled history entry
+// submits it by default and keeps it for editing if HistoryEnterSubmits is
+// false.
+func TestHistoryEnterSubmits(t *testing.T) {
+	tests := []struct {
+		name    string
+		submits *bool
+		want    bool
+	}{
+		{"default", nil, true},
+		{"explicit true", &[]bool{true}[0], true},
+		{"false", new(bool), false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			m := NewPromptModel(PromptConfig{
+				HistoryEnterSubmits: tc.submits,
+			})
+			typeText(m, "SELECT 1;\n")
+			pressKeys(m, tea.KeyUp, tea.KeyEnter)
+
+			submitted := m.history.Len() == 2
+			if submitted != tc.want {
+				t.Fatalf("expected submitted to be %v", tc.want)
+			}
+			if tc.want {
+				return
+			}
+
+			// The entry stays editable outside of navigation.
+			if m.historyIndex != -1 || m.Value() != "SELECT 1;" {
+				t.Fatalf("expected the entry kept, got %q",
+					m.Value())
+			}
+			typeText(m, "0")
+			if m.Value() != "SELECT 1;0" {
+				t.Fatalf("expected editable entry, got %q",
+					m.Value())
+			}
+		})
+	}
+}
+
+// TestLoadHistoryWhileBrowsing asserts that loading a shorter history while a
+// recalled entry is shown ends history navigation, keeps the input editable
+// and lets navigation continue safely in the new history, which is loaded
+// through the configured store.
+func TestLoadHistoryWhileBrowsing(t *testing.T) {
+	store := newSliceHistory()
+	m := NewPromptModel(PromptConfig{History: store})
+	typeText(m, "a;\nb;\nc;\n")
+	pressKeys(m, tea.KeyUp, tea.KeyUp, tea.KeyUp)
+	if m.historyIndex != 0 {
+		t.Fatalf("expected to browse the oldest entry, got %d",
+			m.historyIndex)
+	}
+
+	m.LoadHistory([]string{"x;"})
+	if m.historyIndex != -1 || m.Value() != "a;" {
+		t.Fatalf("expected navigation to end keeping %q, got %q at %d",
+			"a;", m.Value(), m.historyIndex)
+	}
+	if m.history != store || store.Len() != 1 {
+		t.Fatalf("expected the history loaded into the store")
+	}
+
+	// Up starts at the most recent entry of the new history.
+	pressKeys(m, tea.KeyUp)
+	if m.Value() != "x;" {
+		t.Fatalf("expected the loaded entry, got %q", m.Value())
+	}
+	pressKeys(m, tea.KeyUp, tea.KeyDown, tea.KeyDown)
+	if m.Value() != "" || m.historyIndex != -1 {
+		t.Fatalf("expected empty input after history, got %q",
+			m.Value())
+	}
+}
@@ -0,0 +1,157 @@
+package vprompt
+
+import (
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// cursor is an additional cursor position used for multi-cursor editing.
+type cursor struct {
+	// row is the zero-based row index of the cursor.
+	row int
+
+	// col is the zero-based rune column of the cursor within its row.
+	col int
+}
+
+// handleMultiCursorKey handles a key press while multiple cursors are active.
+// Typed characters and Backspace are applied at every cursor. Any other key
+// (except Ctrl+Down, which adds another cursor) removes the additional cursors
+// and is left to the regular key handling. It reports whether the key was
+// handled.
+func (m *PromptModel) handleMultiCursorKey(msg tea.KeyMsg) bool {
+	// Vi normal mode commands may have changed the lines since the
+	// cursors were added.
+	m.pruneCursors()
+
+	switch msg.Type {
+	case tea.KeyRunes:
+		// Insert the typed characters at every cursor.
+		m.forEachCursor(func() {
+			m.insertRunes(msg.Runes)
+		})
+		return true
+
+	case tea.KeySpace:
+		// Insert a space at every cursor.
+		m.forEachCursor(func() {
+			m.insertCharacter(' ')
+		})
+		return true
+
+	case tea.KeyBackspace:
+		// Delete the character before every cursor. Lines are never
+		// merged, so that the cursors stay on their rows.
+		m.forEachCursor(func() {
+			if m.cursorCol > 0 {
+				m.deleteBeforeCursor()
+			}
+		})
+		return true
+
+	case tea.KeyCtrlDown:
+		// Add yet another cursor below.
+		m.addCursorBelow()
+		return true
+	}
+
+	// Return to single cursor editing for all other keys.
+	m.extraCursors = nil
+
+	return false
+}
+
+// addCursorBelow adds a cursor on the line below the lowest cursor, at the
+// column of the primary cursor (clamped to the line length). Autocompletion
+// is hidden while multiple cursors are active. It does nothing if the lowest
+// cursor is on the last line.
+func (m *PromptModel) addCursorBelow() {
+	// Find the row of the lowest cursor.
+	lowestRow := m.cursorRow
+	for _, c := range m.extraCursors {
+		lowestRow = max(lowestRow, c.row)
+	}
+
+	if lowestRow >= len(m.lines)-1 {
+		return
+	}
+
+	row := lowestRow + 1
+	m.extraCursors = append(m.extraCursors, cursor{
+		row: row,
+		col: min(m.cursorCol, m.lineLen(row)),
+	})
+
+	m.clearAutocomplete()
+}
+
+// pruneCursors drops the additional cursors that no longer have a row of their
+// own, e.g., because lines were deleted, and clamps the remaining ones to the
+// length of their lines.
+func (m *PromptModel) pruneCursors() {
+	rows := map[int]bool{m.cursorRow: true}
+
+	cursors := m.extraCursors[:0]
+	for _, c := range m.extraCursors {
+		if c.row >= len(m.lines) || rows[c.row] {
+			continue
+		}
+		rows[c.row] = true
+
+		c.col = min(c.col, m.lineLen(c.row))
+		cursors = append(cursors, c)
+	}
+
+	m.extraCursors = cursors
+}
+
+// forEachCursor calls fn once for the primary cursor and once for every
+// additional cursor. During each call, the respective cursor is the active
+// one (cursorRow and cursorCol), so existing single cursor editing methods
+// can be reused. Since every cursor is on its own row, edits at one cursor
+// don't shift the others, as long as fn doesn't add or remove lines.
+func (m *PromptModel) forEachCursor(fn func()) {
+	// Apply the edit at the primary cursor.
+	fn()
+
+	// Apply the edit at every additional cursor, remembering where
+	// each one ends up.
+	primaryRow, primaryCol := m.cursorRow, m.cursorCol
+	for i, c := range m.extraCursors {
+		m.cursorRow, m.cursorCol = c.row, c.col
+		fn()
+		m.extraCursors[i] = cursor{row: m.cursorRow, col: m.cursorCol}
+	}
+	m.cursorRow, m.cursorCol = primaryRow, primaryCol
+}
+
+// isCursorAt reports whether the primary or any additional cursor is at the
+// given position.
+func (m PromptModel) isCursorAt(row, col int) bool {
+	if row == m.cursorRow && col == m.cursorCol {
+		return true
+	}
+
+	for _, c := range m.extraCursors {
+		if row == c.row && col == c.col {
+			return true
+		}
+	}
+
+	return false
+}
+
+// hasCursorOnRow reports whether the primary or any additional cursor is on
+// the given row.
+func (m PromptModel) hasCursorOnRow(row int) bool {
+	if row == m.cursorRow {
+		return true
+	}
+
+	for _, c := range m.extraCursors {
+		if row == c.row {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,82 @@
+package vprompt
+
+import (
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// TestMultiCursorTyping tests that typed characters, spaces and Backspace are
+// applied at every cursor and that other keys return to a single cursor.
+func TestMultiCursorTyping(t *testing.T) {
+	m := NewPromptModel(PromptConfig{})
+	typeText(m, "a,\nbb,\nc")
+	pressKeys(m, tea.KeyCtrlHome, tea.KeyCtrlDown, tea.KeyCtrlDown)
+	if len(m.extraCursors) != 2 {
+		t.Fatalf("expected 2 extra cursors, got %d",
+			len(m.extraCursors))
+	}
+
+	typeText(m, "x y")
+	if got := m.getCurrentInput(); got != "x ya,\nx ybb,\nx yc" {
+		t.Fatalf("expected typing at every cursor, got %q", got)
+	}
+
+	pressKeys(m, tea.KeyBackspace)
+	if got := m.getCurrentInput(); got != "x a,\nx bb,\nx c" {
+		t.Fatalf("expected Backspace at every cursor, got %q", got)
+	}
+	if !m.isCursorAt(1, 2) || !m.isCursorAt(2, 2) {
+		t.Fatalf("expected extra cursors to follow the edits")
+	}
+
+	// Moving the cursor returns to single cursor editing.
+	pressKeys(m, tea.KeyRight)
+	typeText(m, "z")
+	if len(m.extraCursors) != 0 {
+		t.Fatalf("expected extra cursors to be removed")
+	}
+	if got := m.getCurrentInput(); got != "x az,\nx bb,\nx c" {
+		t.Fatalf("expected typing at the primary cursor only, got %q",
+			got)
+	}
+}
+
+// TestMultiCursorViNormal tests that typed characters are Vi commands applied
+// at the primary cursor in normal mode, while insert mode types at every
+// cursor.
+func TestMultiCursorViNormal(t *testing.T) {
+	m := newViNormalModel("abc\nabc", 0, 0)
+	pressKeys(m, tea.KeyCtrlDown)
+	if len(m.extraCursors) != 1 {
+		t.Fatalf("expected an extra cursor in normal mode")
+	}
+
+	typeText(m, "x")
+	if got := m.getCurrentInput(); got != "bc\nabc" {
+		t.Fatalf("expected x to delete at the primary cursor only, "+
+			"got %q", got)
+	}
+
+	typeText(m, "iz")
+	if got := m.getCurrentInput(); got != "zbc\nzabc" {
+		t.Fatalf("expected insert mode typing at every cursor, got %q",
+			got)
+	}
+}
+
+// TestMultiCursorStaleRows tests that additional cursors whose lines were
+// deleted in Vi normal mode are dropped instead of being edited.
+func TestMultiCursorStaleRows(t *testing.T) {
+	m := newViNormalModel("a\nb", 0, 0)
+	pressKeys(m, tea.KeyCtrlDown)
+	typeText(m, "ddix")
+
+	if got := m.getCurrentInput(); got != "xb" {
+		t.Fatalf("expected typing at the primary cursor only, got %q",
+			got)
+	}
+	if len(m.extraCursors) != 0 {
+		t.Fatalf("expected the stale cursor to be dropped")
+	}
+}
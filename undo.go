@@ -0,0 +1,100 @@
+package vprompt
+
+import "time"
+
+// snapshot returns a copy of the current input and cursor position.
+func (m *PromptModel) snapshot() draft {
+	return draft{
+		lines:     append([]string{}, m.lines...),
+		cursorRow: m.cursorRow,
+		cursorCol: m.cursorCol,
+	}
+}
+
+// markEdited marks the input as edited by the current key press. It must be
+// called by every edit helper right before it changes the input. The first
+// edit of a key press takes a snapshot of the input for undo, unless the edit
+// is merged into the current undo group.
+func (m *PromptModel) markEdited() {
+	if m.edited {
+		return
+	}
+	m.edited = true
+
+	if !m.coalesceEdit(m.keyTime) {
+		before := m.snapshot()
+		m.undoBefore = &before
+	}
+}
+
+// coalesceEdit returns whether an edit made at the given time is merged into
+// the current undo group. Edits made within the configured
+// UndoCoalesceWindow of the previous edit are merged, unless the group
+// already holds UndoGroupMaxEdits edits.
+func (m *PromptModel) coalesceEdit(now time.Time) bool {
+	window := m.config.UndoCoalesceWindow
+	maxEdits := m.config.UndoGroupMaxEdits
+
+	return len(m.undoStack) > 0 && !m.lastEditTime.IsZero() &&
+		now.Sub(m.lastEditTime) <= window &&
+		(maxEdits <= 0 || m.undoGroupEdits < maxEdits)
+}
+
+// recordUndo records the edit made by the current key press, received at the
+// given time, for undo. The snapshot taken by markEdited starts a new undo
+// group, otherwise the edit is merged into the current one. Key presses that
+// didn't edit the input are ignored.
+func (m *PromptModel) recordUndo(now time.Time) {
+	// The input was reset by a submission, there is nothing to undo.
+	if m.undoReset {
+		m.undoReset = false
+		return
+	}
+
+	if !m.edited {
+		return
+	}
+
+	if m.undoBefore != nil {
+		m.undoStack = append(m.undoStack, *m.undoBefore)
+		m.undoBefore = nil
+		m.undoGroupEdits = 0
+	}
+
+	m.undoGroupEdits++
+	m.lastEditTime = now
+}
+
+// Undo reverts the input to the state before the most recent undo group. It
+// does nothing if there is nothing to undo. It is bound to Ctrl+Z.
+func (m *PromptModel) Undo() {
+	if len(m.undoStack) == 0 {
+		return
+	}
+
+	last := m.undoStack[len(m.undoStack)-1]
+	m.undoStack = m.undoStack[:len(m.undoStack)-1]
+
+	m.edited = true
+	m.lines = last.lines
+	m.cursorRow = last.cursorRow
+	m.cursorCol = last.cursorCol
+
+	// The next edit always starts a new undo group.
+	m.lastEditTime = time.Time{}
+
+	m.clearAutocomplete()
+}
+
+// UndoGroups returns the number of undo groups that can be reverted with Undo.
+func (m *PromptModel) UndoGroups() int {
+	return len(m.undoStack)
+}
+
+// resetUndo discards all undo groups, e.g., after the input was submitted.
+func (m *PromptModel) resetUndo() {
+	m.undoStack = nil
+	m.undoGroupEdits = 0
+	m.lastEditTime = time.Time{}
+	m.undoReset = true
+}
@@ -0,0 +1,170 @@
+package vprompt
+
+import (
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// typeAt types the given text one key press at a time, each one received the
+// given gap after the previous one, starting at the given time. It returns the
+// time of the last key press.
+func typeAt(m *PromptModel, text string, start time.Time,
+	gap time.Duration) time.Time {
+
+	now := start
+	for i, r := range text {
+		if i > 0 {
+			now = now.Add(gap)
+		}
+		m.handleKeyMsg(tea.KeyMsg{
+			Type: tea.KeyRunes, Runes: []rune{r},
+		}, now)
+	}
+
+	return now
+}
+
+// TestUndoCoalescing tests that edits made within the coalescing window are
+// merged into a single undo group while slower edits start new groups.
+func TestUndoCoalescing(t *testing.T) {
+	m := NewPromptModel(PromptConfig{
+		UndoCoalesceWindow: time.Second,
+	})
+	start := time.Unix(1000, 0)
+
+	// Fast typing forms a single group.
+	last := typeAt(m, "abc", start, 100*time.Millisecond)
+	if got := m.UndoGroups(); got != 1 {
+		t.Fatalf("expected 1 undo group, got %d", got)
+	}
+
+	// A pause longer than the window starts a new group.
+	typeAt(m, "de", last.Add(2*time.Second), 100*time.Millisecond)
+	if got := m.UndoGroups(); got != 2 {
+		t.Fatalf("expected 2 undo groups, got %d", got)
+	}
+
+	m.Undo()
+	if got := m.getCurrentInput(); got != "abc" {
+		t.Fatalf("expected %q after first undo, got %q", "abc", got)
+	}
+
+	m.Undo()
+	if got := m.getCurrentInput(); got != "" {
+		t.Fatalf("expected empty input after second undo, got %q", got)
+	}
+	if m.cursorRow != 0 || m.cursorCol != 0 {
+		t.Fatalf("expected cursor at 0,0, got %d,%d", m.cursorRow,
+			m.cursorCol)
+	}
+}
+
+// TestUndoGroupMaxEdits tests that a group holds at most UndoGroupMaxEdits
+// edits even if they are all made within the coalescing window.
+func TestUndoGroupMaxEdits(t *testing.T) {
+	m := NewPromptModel(PromptConfig{
+		UndoCoalesceWindow: time.Second,
+		UndoGroupMaxEdits:  2,
+	})
+
+	typeAt(m, "abcde", time.Unix(1000, 0), 10*time.Millisecond)
+	if got := m.UndoGroups(); got != 3 {
+		t.Fatalf("expected 3 undo groups, got %d", got)
+	}
+
+	m.Undo()
+	if got := m.getCurrentInput(); got != "abcd" {
+		t.Fatalf("expected %q after undo, got %q", "abcd", got)
+	}
+}
+
+// TestUndoIgnoresNonEdits tests that key presses that don't change the input
+// neither create undo groups nor end the current one, and that an edit right
+// after an undo starts a new group.
+func TestUndoIgnoresNonEdits(t *testing.T) {
+	m := NewPromptModel(PromptConfig{
+		UndoCoalesceWindow: time.Second,
+	})
+	start := time.Unix(1000, 0)
+
+	last := typeAt(m, "ab", start, 10*time.Millisecond)
+	m.handleKeyMsg(tea.KeyMsg{Type: tea.KeyLeft}, last)
+	m.handleKeyMsg(tea.KeyMsg{Type: tea.KeyRight}, last)
+	typeAt(m, "c", last.Add(10*time.Millisecond), 0)
+	if got := m.UndoGroups(); got != 1 {
+		t.Fatalf("expected 1 undo group, got %d", got)
+	}
+
+	m.handleKeyMsg(tea.KeyMsg{Type: tea.KeyCtrlZ}, last)
+	if got := m.getCurrentInput(); got != "" {
+		t.Fatalf("expected empty input after undo, got %q", got)
+	}
+
+	// Typing right after the undo must not merge into a stale group.
+	typeAt(m, "x", last.Add(20*time.Millisecond), 0)
+	typeAt(m, "y", last.Add(30*time.Millisecond), 0)
+	if got := m.UndoGroups(); got != 1 {
+		t.Fatalf("expected 1 undo group, got %d", got)
+	}
+}
+
+// TestEditEventOnlyOnChange tests that edit events are emitted for key presses
+// that change the input only.
+func TestEditEventOnlyOnChange(t *testing.T) {
+	var edits []string
+	m := NewPromptModel(PromptConfig{
+		EventFn: func(ev Event) {
+			if ev.Kind == EventEdit {
+				edits = append(edits, ev.Text)
+			}
+		},
+	})
+
+	typeText(m, "ab")
+	pressKeys(m, tea.KeyLeft, tea.KeyBackspace, tea.KeyHome,
+		tea.KeyBackspace)
+	want := []string{"a", "ab", "b"}
+	if len(edits) != len(want) {
+		t.Fatalf("expected edits %q, got %q", want, edits)
+	}
+	for i := range want {
+		if edits[i] != want[i] {
+			t.Fatalf("expected edits %q, got %q", want, edits)
+		}
+	}
+}
+
+// TestUndoAfterReplay tests that replayed key presses are tracked for undo and
+// reported as edit events just like typed ones.
+func TestUndoAfterReplay(t *testing.T) {
+	var edits int
+	m := NewPromptModel(PromptConfig{
+		EventFn: func(ev Event) {
+			if ev.Kind == EventEdit {
+				edits++
+			}
+		},
+	})
+
+	m.Replay([]tea.KeyMsg{
+		{Type: tea.KeyRunes, Runes: []rune("ab")},
+		{Type: tea.KeyBackspace},
+	})
+	if got := m.getCurrentInput(); got != "a" {
+		t.Fatalf("expected %q after replay, got %q", "a", got)
+	}
+	if edits != 2 {
+		t.Fatalf("expected 2 edit events, got %d", edits)
+	}
+
+	// Without coalescing, every replayed edit is its own undo group.
+	if got := m.UndoGroups(); got != 2 {
+		t.Fatalf("expected 2 undo groups, got %d", got)
+	}
+	m.Undo()
+	if got := m.getCurrentInput(); got != "ab" {
+		t.Fatalf("expected %q after undo, got %q", "ab", got)
+	}
+}
@@ -0,0 +1,411 @@
+package vprompt
+
+import (
+	"unicode"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// viState describes the current state of the optional Vi editing mode.
+type viState int
+
+const (
+	// viInsert is the state in which typed characters are inserted into
+	// the input, just like without Vi mode. This is the initial state.
+	viInsert viState = iota
+
+	// viNormal is the state in which typed characters are interpreted as
+	// Vi commands, e.g., movements.
+	viNormal
+)
+
+// handleViKey dispatches a key press according to the current Vi state. It
+// reports whether the key was handled. Keys that aren't handled are processed
+// by the regular key handling.
+func (m *PromptModel) handleViKey(msg tea.KeyMsg) (bool, tea.Cmd) {
+	switch m.viState {
+	case viInsert:
+		// Esc returns to normal mode instead of quitting.
+		if msg.Type == tea.KeyEsc {
+			m.enterViNormal()
+			return true, nil
+		}
+
+		// All other keys are handled as usual in insert mode.
+		return false, nil
+
+	case viNormal:
+		return m.handleViNormalKey(msg)
+	}
+
+	return false, nil
+}
+
+// handleViNormalKey handles a key press in Vi normal mode. Typed characters
+// are interpreted as commands. Other keys, such as Enter, the arrow keys or
+// Ctrl+C, are left to the regular key handling.
+func (m *PromptModel) handleViNormalKey(msg tea.KeyMsg) (bool, tea.Cmd) {
+	switch msg.Type {
+	case tea.KeyRunes:
+		// Interpret every typed character as a separate command.
+		for _, r := range msg.Runes {
+			m.handleViNormalCommand(r)
+		}
+		return true, nil
+
+	case tea.KeySpace:
+		// Space moves right, like 'l'.
+		m.moveCursorRight()
+		return true, nil
+
+	case tea.KeyBackspace:
+		// Backspace moves left, like 'h'.
+		m.moveCursorLeft()
+		return true, nil
+
+	case tea.KeyEsc:
+		// Already in normal mode, Esc does nothing.
+		return true, nil
+	}
+
+	return false, nil
+}
+
+// handleViNormalCommand executes the Vi normal mode command for the given
+// character. Unknown commands are ignored.
+func (m *PromptModel) handleViNormalCommand(r rune) {
+	// Complete a pending operator with this character as its motion.
+	if m.viPendingOp != 0 {
+		m.handleViOperator(m.viPendingOp, r)
+		m.viPendingOp = 0
+		return
+	}
+
+	switch r {
+	case 'h':
+		// Move the cursor left.
+		m.moveCursorLeft()
+
+	case 'j':
+		// Move the cursor down or navigate history.
+		m.handleDownArrow()
+
+	case 'k':
+		// Move the cursor up or navigate history.
+		m.handleUpArrow()
+
+	case 'l':
+		// Move the cursor right.
+		m.moveCursorRight()
+
+	case 'w':
+		// Move to the start of the next word.
+		m.viWordForward()
+
+	case 'b':
+		// Move to the start of the previous word.
+		m.viWordBackward()
+
+	case 'e':
+		// Move to the end of the current or next word.
+		m.viWordEnd()
+
+	case 'x':
+		// Delete the character under the cursor.
+		m.viDeleteChar()
+
+	case 'd':
+		// Start a delete operator, completed by the next character.
+		m.viPendingOp = r
+
+	case 'i':
+		// Insert before the cursor.
+		m.viState = viInsert
+
+	case 'a':
+		// Append after the cursor, without wrapping to the next line.
+		if m.cursorCol < m.lineLen(m.cursorRow) {
+			m.cursorCol++
+		}
+		m.viState = viInsert
+	}
+}
+
+// handleViOperator executes the pending operator op with the given motion.
+// Unsupported combinations are ignored.
+func (m *PromptModel) handleViOperator(op, motion rune) {
+	if op != 'd' {
+		return
+	}
+
+	switch motion {
+	case 'd':
+		// Delete the current line.
+		m.viDeleteLine()
+
+	case 'w':
+		// Delete up to the start of the next word.
+		m.viDeleteWord()
+	}
+}
+
+// viDeleteChar deletes the character under the cursor (Vi 'x') and stores it
+// in the register. At the end of a line, nothing is deleted.
+func (m *PromptModel) viDeleteChar() {
+	line := m.lines[m.cursorRow]
+	offset := runeOffset(line, m.cursorCol)
+	if offset >= len(line) {
+		return
+	}
+
+	m.viRegister = line[offset : offset+firstGraphemeSize(line[offset:])]
+	m.deleteAtCursor()
+}
+
+// viDeleteLine deletes the current line (Vi 'dd') and stores it, including
+// its line break, in the register. The cursor moves to the start of the line
+// that takes its place.
+func (m *PromptModel) viDeleteLine() {
+	m.viRegister = m.lines[m.cursorRow] + "\n"
+
+	// Remove the current line from the slice by slicing around it.
+	m.markEdited()
+	m.lines = append(m.lines[:m.cursorRow], m.lines[m.cursorRow+1:]...)
+
+	// Never leave the input without any lines.
+	m.ensureNonEmpty()
+
+	// Keep the cursor on a valid row.
+	m.cursorRow = min(m.cursorRow, len(m.lines)-1)
+	m.cursorCol = 0
+}
+
+// viDeleteWord deletes from the cursor to the start of the next word (Vi 'dw')
+// and stores the deleted text in the register. Like in Vi, the deletion stops
+// at the end of the current line.
+func (m *PromptModel) viDeleteWord() {
+	start := m.viPos()
+	end := m.newViBuffer().wordForward(start)
+
+	runes := []rune(m.lines[start.row])
+	if end.row != start.row {
+		end = viPos{row: start.row, col: len(runes)}
+	}
+
+	m.viRegister = string(runes[start.col:end.col])
+	m.markEdited()
+	m.lines[start.row] = string(runes[:start.col]) +
+		string(runes[end.col:])
+	m.cursorCol = start.col
+}
+
+// enterViNormal switches to Vi normal mode. Suggestions are hidden since they
+// can't be applied in normal mode.
+func (m *PromptModel) enterViNormal() {
+	m.viState = viNormal
+	m.clearAutocomplete()
+}
+
+// viCharClass groups runes for Vi word motions. A word is a sequence of runes
+// of the same class, separated by whitespace or a change of class.
+type viCharClass int
+
+const (
+	// viClassSpace covers whitespace and line ends.
+	viClassSpace viCharClass = iota
+
+	// viClassWord covers word characters as defined by IsWordCharFn.
+	viClassWord
+
+	// viClassPunct covers all other characters, e.g., punctuation.
+	viClassPunct
+)
+
+// viPos is a position within the input used by Vi motions. A column equal to
+// the line length denotes the end of the line.
+type viPos struct {
+	row int
+	col int
+}
+
+// viBuffer is a rune based view of the input lines used to compute Vi motions
+// across line boundaries.
+type viBuffer struct {
+	// lines holds the runes of every input line.
+	lines [][]rune
+
+	// isWordChar defines which runes belong to the word class.
+	isWordChar IsWordCharFunc
+}
+
+// newViBuffer creates a viBuffer from the current input lines.
+func (m *PromptModel) newViBuffer() *viBuffer {
+	lines := make([][]rune, len(m.lines))
+	for i, line := range m.lines {
+		lines[i] = []rune(line)
+	}
+
+	return &viBuffer{
+		lines:      lines,
+		isWordChar: m.config.IsWordCharFn,
+	}
+}
+
+// class returns the character class at the given position. Line ends are
+// treated as whitespace.
+func (b *viBuffer) class(p viPos) viCharClass {
+	line := b.lines[p.row]
+	if p.col >= len(line) {
+		return viClassSpace
+	}
+
+	switch r := line[p.col]; {
+	case unicode.IsSpace(r):
+		return viClassSpace
+
+	case b.isWordChar(r):
+		return viClassWord
+
+	default:
+		return viClassPunct
+	}
+}
+
+// next advances the position by one rune, continuing at the start of the next
+// line after a line end. It returns false if the end of the input is reached.
+func (b *viBuffer) next(p *viPos) bool {
+	switch {
+	case p.col < len(b.lines[p.row]):
+		p.col++
+
+	case p.row < len(b.lines)-1:
+		p.row++
+		p.col = 0
+
+	default:
+		return false
+	}
+
+	return true
+}
+
+// prev moves the position back by one rune, continuing at the end of the
+// previous line from a line start. It returns false if the start of the input
+// is reached.
+func (b *viBuffer) prev(p *viPos) bool {
+	switch {
+	case p.col > 0:
+		p.col--
+
+	case p.row > 0:
+		p.row--
+		p.col = len(b.lines[p.row])
+
+	default:
+		return false
+	}
+
+	return true
+}
+
+// wordForward returns the position of the start of the next word after p.
+func (b *viBuffer) wordForward(p viPos) viPos {
+	// Skip the rest of the current word, if any.
+	if class := b.class(p); class != viClassSpace {
+		for b.class(p) == class {
+			if !b.next(&p) {
+				return p
+			}
+		}
+	}
+
+	// Skip whitespace and line ends up to the next word.
+	for b.class(p) == viClassSpace {
+		if !b.next(&p) {
+			break
+		}
+	}
+
+	return p
+}
+
+// wordBackward returns the position of the start of the word before p, or of
+// the word p is in if p isn't at its start.
+func (b *viBuffer) wordBackward(p viPos) viPos {
+	// Step back and skip whitespace and line ends.
+	if !b.prev(&p) {
+		return p
+	}
+	for b.class(p) == viClassSpace {
+		if !b.prev(&p) {
+			return p
+		}
+	}
+
+	// Move back to the first rune of the word.
+	class := b.class(p)
+	for {
+		prev := p
+		if !b.prev(&prev) || b.class(prev) != class {
+			break
+		}
+		p = prev
+	}
+
+	return p
+}
+
+// wordEnd returns the position of the last rune of the word after p, or of the
+// word p is in if p isn't at its end.
+func (b *viBuffer) wordEnd(p viPos) viPos {
+	// Step forward and skip whitespace and line ends.
+	if !b.next(&p) {
+		return p
+	}
+	for b.class(p) == viClassSpace {
+		if !b.next(&p) {
+			return p
+		}
+	}
+
+	// Move forward to the last rune of the word.
+	class := b.class(p)
+	for {
+		next := p
+		if !b.next(&next) || b.class(next) != class {
+			break
+		}
+		p = next
+	}
+
+	return p
+}
+
+// viWordForward moves the cursor to the start of the next word (Vi 'w').
+func (m *PromptModel) viWordForward() {
+	m.setViPos(m.newViBuffer().wordForward(m.viPos()))
+}
+
+// viWordBackward moves the cursor to the start of the previous word (Vi 'b').
+func (m *PromptModel) viWordBackward() {
+	m.setViPos(m.newViBuffer().wordBackward(m.viPos()))
+}
+
+// viWordEnd moves the cursor to the end of the current or next word (Vi 'e').
+func (m *PromptModel) viWordEnd() {
+	m.setViPos(m.newViBuffer().wordEnd(m.viPos()))
+}
+
+// viPos returns the current cursor position, clamped to the line bounds.
+func (m *PromptModel) viPos() viPos {
+	return viPos{
+		row: m.cursorRow,
+		col: min(m.cursorCol, m.lineLen(m.cursorRow)),
+	}
+}
+
+// setViPos moves the cursor to the given position.
+func (m *PromptModel) setViPos(p viPos) {
+	m.cursorRow = p.row
+	m.cursorCol = p.col
+}
@@ -0,0 +1,201 @@
+package vprompt
+
+import (
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// TestViNavigation tests moving the cursor with h, j, k and l in Vi normal
+// mode as well as switching between the insert and normal states.
+func TestViNavigation(t *testing.T) {
+	m := NewPromptModel(PromptConfig{ViMode: true})
+	typeText(m, "abc\nde")
+	if m.viState != viInsert {
+		t.Fatalf("expected to start in insert mode")
+	}
+
+	// Esc enters normal mode instead of quitting.
+	_, cmd := m.Update(tea.KeyMsg{Type: tea.KeyEsc})
+	if cmd != nil || m.viState != viNormal {
+		t.Fatalf("expected Esc to enter normal mode")
+	}
+
+	steps := []struct {
+		keys string
+		row  int
+		col  int
+	}{
+		{keys: "h", row: 1, col: 1},
+		{keys: "k", row: 0, col: 1},
+		{keys: "ll", row: 0, col: 3},
+		{keys: "hj", row: 1, col: 2},
+		{keys: "hh", row: 1, col: 0},
+	}
+	for _, step := range steps {
+		typeText(m, step.keys)
+		if m.cursorRow != step.row || m.cursorCol != step.col {
+			t.Fatalf("after %q: expected cursor %d:%d, got %d:%d",
+				step.keys, step.row, step.col, m.cursorRow,
+				m.cursorCol)
+		}
+	}
+	if got := m.getCurrentInput(); got != "abc\nde" {
+		t.Fatalf("expected normal mode keys not to be inserted, "+
+			"got %q", got)
+	}
+
+	// 'i' inserts before the cursor.
+	typeText(m, "ix")
+	if m.viState != viInsert || m.getCurrentInput() != "abc\nxde" {
+		t.Fatalf("expected insert before the cursor, got %q",
+			m.getCurrentInput())
+	}
+
+	// 'a' appends after the cursor.
+	pressKeys(m, tea.KeyEsc)
+	typeText(m, "ay")
+	if m.viState != viInsert || m.getCurrentInput() != "abc\nxdye" {
+		t.Fatalf("expected append after the cursor, got %q",
+			m.getCurrentInput())
+	}
+}
+
+// newViNormalModel returns a model in Vi normal mode holding the given input
+// with the cursor at the given position.
+func newViNormalModel(input string, row, col int) *PromptModel {
+	m := NewPromptModel(PromptConfig{ViMode: true})
+	typeText(m, input)
+	pressKeys(m, tea.KeyEsc)
+	m.cursorRow, m.cursorCol = row, col
+
+	return m
+}
+
+// TestViWordMotions tests the w, b and e motions within and across lines.
+func TestViWordMotions(t *testing.T) {
+	const input = "SELECT a+b, c\n  FROM t"
+
+	tests := []struct {
+		name   string
+		motion string
+		from   viPos
+		to     viPos
+	}{
+		{"w to next word", "w", viPos{0, 0}, viPos{0, 7}},
+		{"w to punctuation", "w", viPos{0, 7}, viPos{0, 8}},
+		{"w from punctuation", "w", viPos{0, 8}, viPos{0, 9}},
+		{"w across lines", "w", viPos{0, 12}, viPos{1, 2}},
+		{"w at the end", "w", viPos{1, 7}, viPos{1, 8}},
+		{"b to previous word", "b", viPos{0, 7}, viPos{0, 0}},
+		{"b within word", "b", viPos{0, 3}, viPos{0, 0}},
+		{"b across lines", "b", viPos{1, 2}, viPos{0, 12}},
+		{"b at the start", "b", viPos{0, 0}, viPos{0, 0}},
+		{"e within word", "e", viPos{0, 0}, viPos{0, 5}},
+		{"e to next word", "e", viPos{0, 5}, viPos{0, 7}},
+		{"e across lines", "e", viPos{0, 12}, viPos{1, 5}},
+		{"repeated w", "www", viPos{0, 0}, viPos{0, 9}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			m := newViNormalModel(
+				input, test.from.row, test.from.col,
+			)
+			typeText(m, test.motion)
+
+			got := viPos{m.cursorRow, m.cursorCol}
+			if got != test.to {
+				t.Fatalf("expected %v, got %v", test.to, got)
+			}
+			if m.getCurrentInput() != input {
+				t.Fatalf("expected input to be unchanged")
+			}
+		})
+	}
+}
+
+// TestViDeleteOperators tests the x, dd and dw operators, the resulting input
+// and cursor as well as the deleted text stored in the register.
+func TestViDeleteOperators(t *testing.T) {
+	const input = "SELECT a, b\nFROM t\nWHERE x"
+
+	tests := []struct {
+		name     string
+		keys     string
+		from     viPos
+		want     string
+		cursor   viPos
+		register string
+	}{{
+		name:     "x",
+		keys:     "x",
+		from:     viPos{0, 7},
+		want:     "SELECT , b\nFROM t\nWHERE x",
+		cursor:   viPos{0, 7},
+		register: "a",
+	}, {
+		name:   "x at line end",
+		keys:   "x",
+		from:   viPos{1, 6},
+		want:   input,
+		cursor: viPos{1, 6},
+	}, {
+		name:     "dd",
+		keys:     "dd",
+		from:     viPos{1, 3},
+		want:     "SELECT a, b\nWHERE x",
+		cursor:   viPos{1, 0},
+		register: "FROM t\n",
+	}, {
+		name:     "dd on last line",
+		keys:     "dd",
+		from:     viPos{2, 2},
+		want:     "SELECT a, b\nFROM t",
+		cursor:   viPos{1, 0},
+		register: "WHERE x\n",
+	}, {
+		name:     "dw",
+		keys:     "dw",
+		from:     viPos{0, 0},
+		want:     "a, b\nFROM t\nWHERE x",
+		cursor:   viPos{0, 0},
+		register: "SELECT ",
+	}, {
+		name:     "dw stops at line end",
+		keys:     "dw",
+		from:     viPos{0, 10},
+		want:     "SELECT a, \nFROM t\nWHERE x",
+		cursor:   viPos{0, 10},
+		register: "b",
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			m := newViNormalModel(
+				input, test.from.row, test.from.col,
+			)
+			typeText(m, test.keys)
+
+			if got := m.getCurrentInput(); got != test.want {
+				t.Fatalf("expected %q, got %q", test.want, got)
+			}
+			cursor := viPos{m.cursorRow, m.cursorCol}
+			if cursor != test.cursor {
+				t.Fatalf("expected cursor %v, got %v",
+					test.cursor, cursor)
+			}
+			if m.viRegister != test.register {
+				t.Fatalf("expected register %q, got %q",
+					test.register, m.viRegister)
+			}
+		})
+	}
+
+	// Deleting every line leaves a single empty line.
+	m := newViNormalModel(input, 0, 0)
+	typeText(m, "dddddddd")
+	if len(m.lines) != 1 || m.lines[0] != "" {
+		t.Fatalf("expected one empty line, got %q", m.lines)
+	}
+}
@@ -4,12 +4,19 @@ package vprompt
 
 import (
 	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 	"unicode"
+	"unicode/utf8"
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
 	"github.com/mattn/go-runewidth"
+	"github.com/rivo/uniseg"
 )
 
 // Suggestion represents a single autocomplete suggestion. It holds the word to
@@ -20,6 +27,12 @@ type Suggestion struct {
 	// Description provides optional context for the suggestion (e.g.,
 	// "Select data from a table").
 	Description string
+	// Group is an optional category of the suggestion. The popup renders a
+	// separator line between consecutive suggestions of different groups.
+	Group string
+	// Preferred marks the best matching suggestion, which is selected when
+	// the popup opens instead of the first one.
+	Preferred bool
 }
 
 // defaultPromptStyle defines the style for the prompt symbols (e.g., "sql> ").
@@ -29,6 +42,22 @@ var defaultPromptStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("212"))
 // defaultCursorStyle defines the style for the cursor block. Dim background.
 var defaultCursorStyle = lipgloss.NewStyle().Background(lipgloss.Color("240"))
 
+// defaultBlurredCursorStyle defines the style for the cursor while the prompt
+// is blurred. Underlined, without a background.
+var defaultBlurredCursorStyle = lipgloss.NewStyle().Underline(true)
+
+// defaultSelectionStyle defines the style for selected text. Blue background.
+var defaultSelectionStyle = lipgloss.NewStyle().Background(lipgloss.Color("24"))
+
+// defaultDisabledStyle defines the style for the input while the prompt is
+// disabled. Faint text.
+var defaultDisabledStyle = lipgloss.NewStyle().Faint(true)
+
+// defaultLineNumberStyle defines the style for the line number gutter. Dimmer
+// grey text.
+var defaultLineNumberStyle = lipgloss.NewStyle().
+	Foreground(lipgloss.Color("242"))
+
 // defaultPopupBoxStyle defines the style for the suggestion popup container.
 // Internal padding, dark grey background, light foreground text.
 var defaultPopupBoxStyle = lipgloss.NewStyle().
@@ -42,6 +71,10 @@ var defaultSelectedItemStyle = lipgloss.NewStyle().
 	Background(lipgloss.Color("60")).
 	Foreground(lipgloss.Color("255"))
 
+// defaultMatchHighlightStyle defines the style for the part of a suggestion
+// matching the typed word fragment. Bold text.
+var defaultMatchHighlightStyle = lipgloss.NewStyle().Bold(true)
+
 // defaultUnselectedItemStyle defines the style for non-highlighted suggestions.
 // Inherits from PopupBox or terminal default.
 var defaultUnselectedItemStyle = lipgloss.NewStyle()
@@ -51,6 +84,21 @@ var defaultUnselectedItemStyle = lipgloss.NewStyle()
 var defaultDescriptionStyle = lipgloss.NewStyle().
 	Foreground(lipgloss.Color("242"))
 
+// defaultGroupSeparatorStyle defines the style for the separator lines between
+// groups of suggestions. Dim grey text.
+var defaultGroupSeparatorStyle = lipgloss.NewStyle().
+	Foreground(lipgloss.Color("240"))
+
+// defaultSuggestionCountStyle defines the style for the suggestion count header
+// of the popup. Dimmer grey, italic text.
+var defaultSuggestionCountStyle = lipgloss.NewStyle().
+	Foreground(lipgloss.Color("242")).
+	Italic(true)
+
+// defaultGhostStyle defines the style for the remainder of an inline
+// suggestion. Dim grey text.
+var defaultGhostStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("240"))
+
 // PromptStyles holds the lipgloss styles used for rendering the prompt UI
 // components.
 type PromptStyles struct {
@@ -58,6 +106,15 @@ type PromptStyles struct {
 	Prompt lipgloss.Style
 	// Cursor is the style for the text cursor.
 	Cursor lipgloss.Style
+	// BlurredCursor is the style for the text cursor while the prompt is
+	// blurred.
+	BlurredCursor lipgloss.Style
+	// Selection is the style for selected text.
+	Selection lipgloss.Style
+	// Disabled is the style for the input while the prompt is disabled.
+	Disabled lipgloss.Style
+	// LineNumber is the style for the line number gutter.
+	LineNumber lipgloss.Style
 	// PopupBox is the style for the suggestion popup container box.
 	PopupBox lipgloss.Style
 	// SelectedItem is the style for the currently selected suggestion line.
@@ -66,18 +123,37 @@ type PromptStyles struct {
 	UnselectedItem lipgloss.Style
 	// Description is the style for the description part of suggestions.
 	Description lipgloss.Style
+	// MatchHighlight is the style for the part of each suggestion that
+	// matches the typed word fragment.
+	MatchHighlight lipgloss.Style
+	// GroupSeparator is the style for the separator lines between groups
+	// of suggestions.
+	GroupSeparator lipgloss.Style
+	// SuggestionCount is the style for the header showing the selected
+	// suggestion and the total number of suggestions.
+	SuggestionCount lipgloss.Style
+	// Ghost is the style for the remainder of an inline suggestion.
+	Ghost lipgloss.Style
 }
 
 // DefaultPromptStyles returns a default set of PromptStyles, initializing all
 // fields.
 func DefaultPromptStyles() PromptStyles {
 	return PromptStyles{
-		Prompt:         defaultPromptStyle,
-		Cursor:         defaultCursorStyle,
-		PopupBox:       defaultPopupBoxStyle,
-		SelectedItem:   defaultSelectedItemStyle,
-		UnselectedItem: defaultUnselectedItemStyle,
-		Description:    defaultDescriptionStyle,
+		Prompt:          defaultPromptStyle,
+		Cursor:          defaultCursorStyle,
+		BlurredCursor:   defaultBlurredCursorStyle,
+		Selection:       defaultSelectionStyle,
+		Disabled:        defaultDisabledStyle,
+		LineNumber:      defaultLineNumberStyle,
+		PopupBox:        defaultPopupBoxStyle,
+		SelectedItem:    defaultSelectedItemStyle,
+		UnselectedItem:  defaultUnselectedItemStyle,
+		Description:     defaultDescriptionStyle,
+		MatchHighlight:  defaultMatchHighlightStyle,
+		GroupSeparator:  defaultGroupSeparatorStyle,
+		SuggestionCount: defaultSuggestionCountStyle,
+		Ghost:           defaultGhostStyle,
 	}
 }
 
@@ -88,32 +164,204 @@ func DefaultPromptStyles() PromptStyles {
 type AutoCompleteFunc func(textBeforeCursor string,
 	wordFragment string) []Suggestion
 
+// CompletionContext holds all information about the current input state that
+// is passed to an AutoCompleteCtxFunc.
+type CompletionContext struct {
+	// TextBeforeCursor is the full text before the cursor, with lines
+	// joined by newlines.
+	TextBeforeCursor string
+
+	// TextAfterCursor is the full text after the cursor, with lines joined
+	// by newlines.
+	TextAfterCursor string
+
+	// WordFragment is the word fragment being typed, ending at the cursor.
+	WordFragment string
+
+	// CursorRow is the zero-based row index of the cursor.
+	CursorRow int
+
+	// CursorCol is the zero-based rune column of the cursor within its
+	// row.
+	CursorCol int
+
+	// Lines holds a copy of all input lines.
+	Lines []string
+}
+
+// AutoCompleteCtxFunc defines the signature for a user-provided function that
+// returns suggestions based on the full CompletionContext. It is an
+// alternative to AutoCompleteFunc for completers that need more context.
+type AutoCompleteCtxFunc func(ctx CompletionContext) []Suggestion
+
 // ExecuteFunc defines the signature for a user-provided function that executes
 // the final input. It receives the complete, joined input string and should
 // return a string representing the output or result of the execution to be
 // displayed to the user.
 type ExecuteFunc func(input string) string
 
+// ExecuteAsyncFunc defines the signature for a user-provided function that
+// executes the final input asynchronously. It receives the complete, joined
+// input string and returns a bubbletea command whose result should be an
+// OutputMsg (or a sequence of OutputChunkMsg followed by OutputDoneMsg).
+type ExecuteAsyncFunc func(input string) tea.Cmd
+
+// PreExecuteFunc defines the signature for a user-provided function that
+// transforms the input before it is executed, e.g., to expand aliases. It
+// receives the complete, joined input string and returns the string to
+// execute.
+type PreExecuteFunc func(input string) string
+
 // IsCompleteFunc defines the signature for a user-provided function that
 // determines if the current multi-line input is complete and ready for
 // execution.
 type IsCompleteFunc func(input string) bool
 
+// HistoryNavigateFunc defines the signature for a user-provided function that
+// is notified whenever a history entry is recalled into the input area. It
+// receives the loaded entry and its index in the history.
+type HistoryNavigateFunc func(entry string, index int)
+
+// EventKind identifies the kind of state change reported by an Event.
+type EventKind int
+
+const (
+	// EventEdit reports that a key press changed the input.
+	EventEdit EventKind = iota
+
+	// EventSubmit reports that the input was submitted for execution.
+	EventSubmit
+
+	// EventComplete reports that a suggestion was applied to the input.
+	EventComplete
+
+	// EventHistoryNavigate reports that a history entry was recalled into
+	// the input.
+	EventHistoryNavigate
+)
+
+// Event describes a change of the prompt state, as reported to the EventFunc.
+type Event struct {
+	// Kind is the kind of the state change.
+	Kind EventKind
+	// Text is the input after an edit, the submitted input, the applied
+	// suggestion or the recalled history entry, depending on the kind.
+	// It is empty while the input is masked.
+	Text string
+	// Index is the history index of a recalled entry. It is only set for
+	// EventHistoryNavigate.
+	Index int
+}
+
+// EventFunc defines the signature for a user-provided function that observes
+// all edits, submissions, completions and history navigations uniformly.
+type EventFunc func(ev Event)
+
+// SuggestionLessFunc defines the signature for a user-provided function that
+// reports whether suggestion a should be ordered before suggestion b when
+// sorting suggestions.
+type SuggestionLessFunc func(a, b Suggestion) bool
+
+// OutputCompleteFunc defines the signature for a user-provided function that
+// is notified when asynchronously delivered output has been finalized.
+type OutputCompleteFunc func()
+
+// IdleFunc defines the signature for a user-provided function that is called
+// when the user stops typing for the configured IdleTimeout. It receives the
+// current input.
+type IdleFunc func(input string)
+
+// idleTickMsg is the internal message delivered once the idle timeout elapses
+// after a key press. It carries the key press sequence number at the time the
+// tick was scheduled, so stale ticks can be recognized.
+type idleTickMsg struct {
+	seq int
+}
+
+// EOFFunc defines the signature for a user-provided function that handles
+// end-of-input, signaled by pressing Ctrl+D on an empty buffer. The returned
+// command (e.g., tea.Quit) is passed on to bubbletea.
+type EOFFunc func() tea.Cmd
+
+// UnhandledKeyFunc defines the signature for a user-provided function that
+// receives key presses not handled by the prompt, e.g., to implement custom
+// shortcuts. The returned command is passed on to bubbletea.
+type UnhandledKeyFunc func(msg tea.KeyMsg) tea.Cmd
+
+// OutputMsg is a bubbletea message carrying the complete result of an
+// asynchronous execution. It replaces the currently displayed output and
+// finalizes it.
+type OutputMsg string
+
+// OutputChunkMsg is a bubbletea message carrying a fragment of streamed
+// output. Each chunk received is appended to the currently displayed output
+// without clearing it, allowing results to be delivered incrementally from a
+// goroutine (e.g., via tea.Program.Send).
+type OutputChunkMsg string
+
+// OutputDoneMsg is a bubbletea message signaling that a stream of
+// OutputChunkMsg has finished. Until it arrives, edits do not clear the
+// displayed output so that partially streamed results remain visible.
+type OutputDoneMsg struct{}
+
 // IsWordCharFunc defines the signature for a user-provided function that
 // determines if a given rune should be considered part of a "word" for
 // autocompletion purposes.
 type IsWordCharFunc func(r rune) bool
 
+// ControlCharPolicy determines how control characters (runes below space,
+// other than newline and tab) are treated when inserted into the input, e.g.,
+// as part of a paste.
+type ControlCharPolicy int
+
+const (
+	// ControlCharDrop silently discards control characters. This is the
+	// default.
+	ControlCharDrop ControlCharPolicy = iota
+
+	// ControlCharEscape replaces control characters with their visible
+	// caret notation (e.g., "^A" for 0x01).
+	ControlCharEscape
+
+	// ControlCharKeep inserts control characters into the input as-is.
+	ControlCharKeep
+)
+
+// CaseTransform determines whether typed letters are automatically converted
+// to a specific case when inserted into the input.
+type CaseTransform int
+
+const (
+	// CaseNone inserts letters as typed. This is the default.
+	CaseNone CaseTransform = iota
+
+	// CaseUpper converts inserted letters to upper case.
+	CaseUpper
+
+	// CaseLower converts inserted letters to lower case.
+	CaseLower
+)
+
 // PromptConfig holds all the customizable settings for the PromptModel.
 type PromptConfig struct {
-	// PromptPrimary is the prompt string for the first line.
+	// PromptPrimary is the prompt string for the first line. It may span
+	// multiple lines, in which case all but the last line are rendered
+	// above the input.
 	PromptPrimary string
 	// PromptSecondary is the prompt string for subsequent lines.
 	PromptSecondary string
 	// AutoCompleteFn is the user function to get autocomplete suggestions.
 	AutoCompleteFn AutoCompleteFunc
+	// AutoCompleteCtxFn is an alternative user function to get
+	// autocomplete suggestions based on the full completion context. If
+	// set, it takes precedence over AutoCompleteFn.
+	AutoCompleteCtxFn AutoCompleteCtxFunc
 	// ExecuteFn is the user function to execute the completed input.
 	ExecuteFn ExecuteFunc
+	// ExecuteAsyncFn is an alternative user function to execute the
+	// completed input asynchronously. If set, it takes precedence over
+	// ExecuteFn.
+	ExecuteAsyncFn ExecuteAsyncFunc
 	// IsCompleteFn is the user function to check if input is complete.
 	IsCompleteFn IsCompleteFunc
 	// IsWordCharFn is the user function to define word boundaries for
@@ -121,19 +369,411 @@ type PromptConfig struct {
 	IsWordCharFn IsWordCharFunc
 	// Styles contains the lipgloss styles for rendering various UI parts.
 	Styles PromptStyles
-	// ShowDescription controls description visibility in suggestions.
+	// ShowDescription controls description visibility in suggestions. It
+	// can be toggled at runtime with Ctrl+/.
 	ShowDescription bool
-	// PopupMaxHeight limits the number of suggestions shown before
-	// scrolling.
+	// PopupMaxHeight limits the number of rows the visible suggestions
+	// take before scrolling, including description lines and group
+	// separators.
 	PopupMaxHeight int
+	// ControlChars determines how control characters in typed or pasted
+	// input are handled. Defaults to dropping them.
+	ControlChars ControlCharPolicy
+	// History is the store used for previously executed commands. If nil,
+	// an in-memory store is used.
+	History HistoryStore
+	// PromptOutputLines controls whether each line of the displayed output
+	// is prefixed with the primary prompt.
+	PromptOutputLines bool
+	// DescriptionMaxWidth limits the display width of suggestion
+	// descriptions. Longer descriptions are wrapped onto subsequent lines
+	// below their suggestion. A value <= 0 disables wrapping.
+	DescriptionMaxWidth int
+	// StablePopupWidth makes the popup width depend on all suggestions
+	// instead of only the visible ones, so the box doesn't resize while
+	// scrolling.
+	StablePopupWidth bool
+	// OnHistoryNavigate is an optional user function called whenever a
+	// history entry is loaded into the input area.
+	OnHistoryNavigate HistoryNavigateFunc
+	// TruncateOutput controls whether output lines wider than the terminal
+	// are cut off with an ellipsis instead of wrapping.
+	TruncateOutput bool
+	// CaseTransform determines whether inserted letters are automatically
+	// converted to upper or lower case. Defaults to no conversion.
+	CaseTransform CaseTransform
+	// SortSuggestions controls whether suggestions are sorted before
+	// display. When false, the completer's order is preserved.
+	SortSuggestions bool
+	// SuggestionLess is the user function used to order suggestions when
+	// SortSuggestions is set. Defaults to alphabetical order by Text.
+	SuggestionLess SuggestionLessFunc
+	// DedupSuggestions controls whether suggestions with duplicate Text
+	// are removed before display, keeping the first occurrence.
+	DedupSuggestions bool
+	// OnOutputComplete is an optional user function called when
+	// asynchronous output has been finalized by an OutputMsg or an
+	// OutputDoneMsg.
+	OnOutputComplete OutputCompleteFunc
+	// RecordKeys controls whether every received key press is recorded
+	// for later inspection and replay, e.g., to reproduce bugs.
+	RecordKeys bool
+	// OnIdle is an optional user function called once the user hasn't
+	// pressed a key for IdleTimeout.
+	OnIdle IdleFunc
+	// IdleTimeout is the duration without key presses after which OnIdle
+	// is called. A value <= 0 disables the idle callback.
+	IdleTimeout time.Duration
+	// ShowPopupScrollbar controls whether a vertical scrollbar is rendered
+	// on the right edge of the popup when not all suggestions fit.
+	ShowPopupScrollbar bool
+	// SubmitChecksComplete controls whether Submit checks the input with
+	// IsCompleteFn first, like Enter does, and doesn't submit incomplete
+	// input.
+	SubmitChecksComplete bool
+	// KeepTrailingBlankLines controls whether consecutive blank lines at
+	// the end of the input are kept while editing instead of being
+	// collapsed.
+	KeepTrailingBlankLines bool
+	// OnEOF is an optional user function called when Ctrl+D is pressed on
+	// an empty buffer. If nil, the application quits.
+	OnEOF EOFFunc
+	// DescriptionBelow controls whether suggestion descriptions are
+	// rendered indented on the line below their suggestion instead of
+	// inline. Each item then takes at least two lines, so fewer items fit
+	// within PopupMaxHeight.
+	DescriptionBelow bool
+	// ViMode enables Vi-style editing with separate insert and normal
+	// states. Esc switches from insert to normal mode instead of quitting.
+	ViMode bool
+	// ImmediateCommands holds inputs (e.g., ".quit") that are executed on
+	// Enter regardless of IsCompleteFn, when the trimmed input matches a
+	// key set to true.
+	ImmediateCommands map[string]bool
+	// PreExecuteFn is an optional user function that transforms the input
+	// before it is passed to the execution function.
+	PreExecuteFn PreExecuteFunc
+	// HistoryStoresTransformed controls whether the history stores the
+	// input as transformed by PreExecuteFn instead of as typed.
+	HistoryStoresTransformed bool
+	// TrailingNewlineAfterOutput controls whether a blank line separates
+	// the displayed output from the prompt below it.
+	TrailingNewlineAfterOutput bool
+	// SoftWrap controls whether input lines wider than the terminal are
+	// wrapped onto multiple visual rows. The Up and Down keys then move
+	// through the visual rows before leaving the line.
+	SoftWrap bool
+	// OutputMaxHeight limits the number of displayed output lines. Taller
+	// output is clipped and can be scrolled with Shift+Up and Shift+Down.
+	// A value <= 0 means no limit.
+	OutputMaxHeight int
+	// Terminator is the string that marks input as complete (e.g., "/").
+	// If set and IsCompleteFn is nil or DefaultIsComplete (as set by
+	// NewPromptConfig), the completeness check is done with
+	// IsCompleteWithTerminator, or with IsCompleteWithTerminatorLine for
+	// the "/" terminator or if TerminatorOnOwnLine is set. A custom
+	// IsCompleteFn is responsible for checking the terminator itself.
+	// Defaults to the semicolon checked by DefaultIsComplete.
+	Terminator string
+	// TerminatorOnOwnLine controls whether the Terminator only completes
+	// the input if it is on a line of its own, like the "/" terminating
+	// PL/SQL blocks, which is always required to be on its own line.
+	TerminatorOnOwnLine bool
+	// OnUnhandledKey is an optional user function called with every key
+	// press the prompt doesn't handle itself.
+	OnUnhandledKey UnhandledKeyFunc
+	// ManualCompleteOnly disables opening the suggestion popup while
+	// typing. The popup then only opens via TriggerComplete (Ctrl+Space),
+	// but typing still updates it while it is open.
+	ManualCompleteOnly bool
+	// ShowTiming controls whether the execution time is appended to the
+	// output. For asynchronous execution, the time until the output
+	// arrives is measured.
+	ShowTiming bool
+	// ShowLineNumbers controls whether a gutter with line numbers is
+	// rendered in front of the prompt of each input line.
+	ShowLineNumbers bool
+	// LineNumbersMultilineOnly restricts the line number gutter to
+	// buffers with more than one line. Only used with ShowLineNumbers.
+	LineNumbersMultilineOnly bool
+	// SelectedPrefix is an optional textual marker (e.g., "> ") rendered
+	// in front of the selected suggestion. Unselected suggestions are
+	// padded to the same width to keep them aligned.
+	SelectedPrefix string
+	// HistoryCursorAtStart places the cursor at the start of recalled
+	// history entries instead of at their end.
+	HistoryCursorAtStart bool
+	// EventFn is an optional user function notified of every change of
+	// the prompt state, e.g., for analytics.
+	EventFn EventFunc
+	// IgnoreEmptyEnter makes Enter a no-op on an empty single-line buffer,
+	// instead of inserting a newline.
+	IgnoreEmptyEnter bool
+	// RightAlignRTL right-aligns input lines with predominantly
+	// right-to-left content (e.g., Arabic or Hebrew) within the render
+	// width. Only the alignment changes: the text is still stored and
+	// rendered in logical order and the cursor moves as it does for
+	// left-to-right text, any bidirectional reordering is left to the
+	// terminal.
+	RightAlignRTL bool
+	// MaxWidth limits the width used for wrapping and truncation,
+	// independent of the terminal width. A value <= 0 means the terminal
+	// width is used.
+	MaxWidth int
+	// SuggestionsOnEmpty are optional suggestions shown in the popup
+	// while the buffer is empty and the prompt is focused, e.g., to make
+	// common commands discoverable. Typing switches to the results of
+	// the completer. Up and Down still navigate the history unless the
+	// suggestions were requested explicitly (Ctrl+Space).
+	SuggestionsOnEmpty []Suggestion
+	// KeepPopupOnHorizontalMove refreshes the suggestions when moving the
+	// cursor left or right, instead of clearing them.
+	KeepPopupOnHorizontalMove bool
+	// UndoCoalesceWindow is the maximum time between consecutive edits
+	// that are merged into the same undo group. Edits further apart start
+	// a new group. Zero means every edit is its own group.
+	UndoCoalesceWindow time.Duration
+	// UndoGroupMaxEdits caps the number of edits merged into a single
+	// undo group. A value <= 0 means no limit.
+	UndoGroupMaxEdits int
+	// MacroKeys maps key strings (e.g., "ctrl+t") to functions producing
+	// text that is inserted at the cursor when the key is pressed, e.g.,
+	// the current date and time.
+	MacroKeys map[string]func() string
+	// TabCycles makes Tab move the selection down through the suggestions
+	// (and Shift+Tab up) instead of accepting the selected one. Enter then
+	// accepts the selection while the popup is visible.
+	TabCycles bool
+	// ShowSuggestionCount controls whether a header showing the position
+	// of the selected suggestion and the total number of suggestions
+	// (e.g., "3/42") is rendered on top of the popup.
+	ShowSuggestionCount bool
+	// MaskInput renders every character of the input as MaskRune, e.g.,
+	// for password input. The buffer still holds the real text, but it is
+	// never stored in the history, recorded with RecordKeys, passed to
+	// the AutoCompleteFn or OnIdle, or carried by the events of EventFn.
+	MaskInput bool
+	// MaskRune is the rune masked input is rendered with. Defaults to a
+	// bullet.
+	MaskRune rune
+	// OnPopupOpen is an optional user function called whenever the
+	// suggestion popup opens.
+	OnPopupOpen func()
+	// OnPopupClose is an optional user function called whenever the
+	// suggestion popup closes.
+	OnPopupClose func()
+	// StripCommentsForComplete makes the completeness check ignore line
+	// comments, so that input such as "SELECT 1; -- done" is complete.
+	StripCommentsForComplete bool
+	// CommentPrefix is the prefix of line comments stripped with
+	// StripCommentsForComplete. Defaults to "--".
+	CommentPrefix string
+	// CompleterChain is an optional list of completers whose suggestions
+	// are merged in list order, dropping duplicates. It takes precedence
+	// over AutoCompleteFn, but not over AutoCompleteCtxFn.
+	CompleterChain []AutoCompleteFunc
+	// KeepPopupAfterApply refreshes the suggestions for the new context
+	// after a suggestion was applied, instead of closing the popup.
+	KeepPopupAfterApply bool
+	// HistoryEnterSubmits controls whether Enter on a recalled history
+	// entry submits it. If it points to false (e.g., new(bool)), Enter
+	// exits history navigation instead, keeping the entry in the input
+	// for editing. If nil, Enter submits the entry.
+	HistoryEnterSubmits *bool
+	// BusyPrompt is an optional prompt (e.g., "... ") rendered in place of
+	// the primary prompt while an asynchronous execution is pending. The
+	// primary prompt is restored once the output arrives.
+	BusyPrompt string
+	// WrapOutput word wraps output lines wider than the terminal (or
+	// MaxWidth) instead of letting them run off screen. Long words are
+	// broken. Embedded newlines are preserved.
+	WrapOutput bool
+	// CollapseBlankLines removes interior blank lines from the input when
+	// it is submitted. Trailing blank lines are always removed.
+	CollapseBlankLines bool
+	// AlignSecondaryPrompt right-aligns the secondary prompt to the width
+	// of the primary prompt, so that the input of all lines starts in the
+	// same column.
+	AlignSecondaryPrompt bool
+	// EOLCursorChar is an optional glyph (e.g., "▉") rendered as the
+	// cursor when it is past the end of the line. Defaults to a space.
+	EOLCursorChar string
+	// InlineSuggestions renders the remainder of the selected suggestion
+	// as dim ghost text after the cursor instead of showing the popup
+	// box. Tab accepts it and Up/Down select other suggestions.
+	InlineSuggestions bool
+	// SuggestionMaxWidth limits the displayed width of each suggestion's
+	// text in the popup. Longer texts are truncated with an ellipsis, but
+	// still inserted fully. A value <= 0 means no limit.
+	SuggestionMaxWidth int
+	// ExactMatchIgnoreCase makes the check for a sole suggestion equal to
+	// the typed word fragment, which hides the popup, ignore case.
+	ExactMatchIgnoreCase bool
+	// MaxSuggestions limits the number of suggestions kept from the
+	// completer. Only the first ones (after deduplication and sorting)
+	// are displayed and navigable. A value <= 0 means no limit.
+	MaxSuggestions int
+	// EmptyOutputText is an optional placeholder (e.g., "OK") rendered as
+	// the output of an execution that returned no output, so that the
+	// execution is still acknowledged. An empty string renders nothing.
+	EmptyOutputText string
 }
 
 // DefaultIsComplete provides a default implementation for IsCompleteFunc. It
 // considers input complete if it ends with a semicolon after trimming
-// whitespace.
+// whitespace. Just a semicolon is not complete.
 func DefaultIsComplete(input string) bool {
 	trimmedInput := strings.TrimSpace(input)
-	return strings.HasSuffix(trimmedInput, ";")
+	return strings.HasSuffix(trimmedInput, ";") && trimmedInput != ";"
+}
+
+// IsCompleteWithTerminator returns an IsCompleteFunc that considers input
+// complete if it ends with the given terminator (e.g., "/" for PL/SQL) after
+// trimming whitespace. It generalizes DefaultIsComplete to terminators other
+// than the semicolon. Just the terminator is not complete.
+func IsCompleteWithTerminator(terminator string) IsCompleteFunc {
+	return func(input string) bool {
+		trimmedInput := strings.TrimSpace(input)
+		return strings.HasSuffix(trimmedInput, terminator) &&
+			trimmedInput != terminator
+	}
+}
+
+// isDefaultIsComplete reports whether the given function is DefaultIsComplete.
+// Functions can't be compared directly, so their code pointers are compared.
+func isDefaultIsComplete(fn IsCompleteFunc) bool {
+	return reflect.ValueOf(fn).Pointer() ==
+		reflect.ValueOf(DefaultIsComplete).Pointer()
+}
+
+// IsCompleteWithTerminatorLine returns an IsCompleteFunc that considers input
+// complete if its last non-blank line consists of just the given terminator
+// after trimming whitespace. This is required for terminators such as the "/"
+// of PL/SQL, which may also appear within the input, e.g., as a division.
+// Just the terminator line without any preceding input is not complete.
+func IsCompleteWithTerminatorLine(terminator string) IsCompleteFunc {
+	return func(input string) bool {
+		lines := strings.Split(strings.TrimSpace(input), "\n")
+		lastLine := strings.TrimSpace(lines[len(lines)-1])
+
+		return lastLine == terminator && len(lines) > 1
+	}
+}
+
+// IsCompleteIgnoringComments returns an IsCompleteFunc that strips line
+// comments starting with the given prefix (e.g., "--") from the input before
+// checking it with the given IsCompleteFunc. This way, input such as
+// "SELECT 1; -- done" is complete for DefaultIsComplete. Comment prefixes
+// inside single or double quotes are ignored.
+func IsCompleteIgnoringComments(prefix string,
+	isComplete IsCompleteFunc) IsCompleteFunc {
+
+	return func(input string) bool {
+		return isComplete(stripLineComments(input, prefix))
+	}
+}
+
+// stripLineComments removes line comments starting with the given prefix from
+// every line of the input. Comment prefixes inside quotes are kept.
+func stripLineComments(input, prefix string) string {
+	if prefix == "" {
+		return input
+	}
+
+	lines := strings.Split(input, "\n")
+	for i, line := range lines {
+		if idx := lineCommentIndex(line, prefix); idx >= 0 {
+			lines[i] = line[:idx]
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// lineCommentIndex returns the byte index of the first comment prefix in the
+// line that isn't inside quotes, or -1 if there is none.
+func lineCommentIndex(line, prefix string) int {
+	// quote is the currently open quote character, or zero if none.
+	var quote rune
+
+	for j, r := range line {
+		switch {
+		case quote != 0:
+			// Inside quotes, only look for the closing quote.
+			if r == quote {
+				quote = 0
+			}
+
+		case r == '\'' || r == '"':
+			quote = r
+
+		case strings.HasPrefix(line[j:], prefix):
+			return j
+		}
+	}
+
+	return -1
+}
+
+// DefaultIsCompleteBalanced provides an alternative implementation for
+// IsCompleteFunc. It considers input complete if all parentheses, brackets and
+// braces are balanced and properly nested, and all single and double quotes
+// are closed. Brackets inside quotes are ignored and a backslash inside quotes
+// escapes the next character.
+func DefaultIsCompleteBalanced(input string) bool {
+	// closing maps each opening bracket to its closing counterpart.
+	closing := map[rune]rune{'(': ')', '[': ']', '{': '}'}
+
+	// stack holds the expected closing brackets of open brackets.
+	var stack []rune
+
+	// quote is the currently open quote character, or zero if none.
+	var quote rune
+
+	escaped := false
+	for _, r := range input {
+		// Inside quotes, only look for the closing quote.
+		if quote != 0 {
+			switch {
+			case escaped:
+				escaped = false
+
+			case r == '\\':
+				escaped = true
+
+			case r == quote:
+				quote = 0
+			}
+
+			continue
+		}
+
+		switch r {
+		case '\'', '"':
+			// Open a quoted section.
+			quote = r
+
+		case '(', '[', '{':
+			// Remember which bracket has to close this one.
+			stack = append(stack, closing[r])
+
+		case ')', ']', '}':
+			// A closing bracket must match the last open one.
+			if len(stack) == 0 || stack[len(stack)-1] != r {
+				return false
+			}
+			stack = stack[:len(stack)-1]
+		}
+	}
+
+	// Complete only if nothing is left open.
+	return quote == 0 && len(stack) == 0
+}
+
+// DefaultSuggestionLess provides a default implementation for
+// SuggestionLessFunc. It orders suggestions alphabetically by their Text.
+func DefaultSuggestionLess(a, b Suggestion) bool {
+	return a.Text < b.Text
 }
 
 // DefaultIsWordChar provides a default implementation for IsWordCharFunc. It
@@ -157,7 +797,8 @@ func NewPromptConfig(primary, secondary string, acFn AutoCompleteFunc,
 		PromptSecondary: secondary,
 		AutoCompleteFn:  acFn,
 		ExecuteFn:       execFn,
-		// Use default semicolon check
+		// Use default semicolon check, which NewPromptModel replaces
+		// by the check for the configured Terminator, if any.
 		IsCompleteFn: DefaultIsComplete,
 		// Use default word character definition
 		IsWordCharFn: DefaultIsWordChar,
@@ -188,8 +829,8 @@ type PromptModel struct {
 	// within the current row.
 	cursorCol int
 
-	// history holds previously executed commands as strings.
-	history []string
+	// history holds previously executed commands.
+	history HistoryStore
 
 	// historyIndex is the current index when navigating history (-1 means
 	// not navigating).
@@ -201,6 +842,11 @@ type PromptModel struct {
 	// showPopup indicates if the suggestion popup should be visible.
 	showPopup bool
 
+	// popupSeeded indicates that the popup shows the SuggestionsOnEmpty
+	// on its own accord rather than by request of the user. Up and Down
+	// navigate the history instead of such a popup.
+	popupSeeded bool
+
 	// selectedSuggestionIndex is the index of the currently highlighted
 	// suggestion in the list.
 	selectedSuggestionIndex int
@@ -217,6 +863,143 @@ type PromptModel struct {
 	// execution. lastOutput stores the string returned by the ExecuteFn to
 	// display temporarily.
 	lastOutput string
+
+	// streaming indicates that output is currently being delivered via
+	// OutputChunkMsg and has not yet been finalized by an OutputDoneMsg.
+	streaming bool
+
+	// width is the terminal width as reported by the last
+	// tea.WindowSizeMsg. Zero means the width is unknown.
+	width int
+
+	// autocompleteDisabled indicates that autocompletion has been turned
+	// off at runtime and the suggestion popup must not be shown.
+	autocompleteDisabled bool
+
+	// recordedKeys holds all received key presses if RecordKeys is set.
+	recordedKeys []tea.KeyMsg
+
+	// replaying indicates that Replay is feeding key presses, which are
+	// not recorded again.
+	replaying bool
+
+	// keySeq is incremented on every key press. It is used to detect
+	// whether the user was idle since an idle tick was scheduled.
+	keySeq int
+
+	// viState is the current state of the Vi editing mode. Only used if
+	// ViMode is set.
+	viState viState
+
+	// viPendingOp is the Vi operator (e.g., 'd') waiting for its motion,
+	// or zero if no operator is pending.
+	viPendingOp rune
+
+	// viRegister holds the text removed by the last Vi delete operation.
+	viRegister string
+
+	// stash holds the draft saved by Stash, or nil if there is none.
+	stash *draft
+
+	// outputScrollOffset is the index of the first visible output line
+	// when the output is clipped to OutputMaxHeight.
+	outputScrollOffset int
+
+	// extraCursors holds the additional cursors for multi-cursor editing,
+	// each on its own row below the primary cursor.
+	extraCursors []cursor
+
+	// blockMode indicates that a rectangular block selection is active.
+	blockMode bool
+
+	// blockAnchor is the corner of the block selection opposite to the
+	// cursor.
+	blockAnchor cursor
+
+	// execStart is the time the pending asynchronous execution was
+	// dispatched, or the zero time if none is pending.
+	execStart time.Time
+
+	// disabled indicates that the prompt ignores input and is rendered
+	// dimmed.
+	disabled bool
+
+	// blurred indicates that the prompt is not focused, so the cursor is
+	// rendered with the blurred cursor style.
+	blurred bool
+
+	// stableWordWidth and stableDescWidth are the maximum display widths of
+	// the words and descriptions of all suggestions, measured when the
+	// suggestions are set if StablePopupWidth is enabled.
+	stableWordWidth int
+	stableDescWidth int
+
+	// eventEmitted tracks whether an event was emitted while handling the
+	// current key press, so that no additional edit event is reported.
+	eventEmitted bool
+
+	// busy indicates that an asynchronous execution is pending, i.e., its
+	// output hasn't arrived completely yet.
+	busy bool
+
+	// undoStack holds the input before each undo group, the most recent
+	// one last.
+	undoStack []draft
+
+	// undoGroupEdits is the number of edits merged into the most recent
+	// undo group.
+	undoGroupEdits int
+
+	// lastEditTime is the time of the most recent edit, or the zero time
+	// if the next edit must start a new undo group.
+	lastEditTime time.Time
+
+	// undoReset indicates that the undo groups were discarded while
+	// handling the current key press, so that it isn't recorded.
+	undoReset bool
+
+	// edited indicates that the input was changed while handling the
+	// current key press. It is set by the edit helpers via markEdited.
+	edited bool
+
+	// undoBefore holds the input before the current key press if its
+	// edit starts a new undo group, nil otherwise.
+	undoBefore *draft
+
+	// keyTime is the time the current key press was received at.
+	keyTime time.Time
+}
+
+// draft is a saved copy of the input lines and the cursor position.
+type draft struct {
+	// lines holds a copy of the saved input lines.
+	lines []string
+
+	// cursorRow is the saved cursor row.
+	cursorRow int
+
+	// cursorCol is the saved cursor column.
+	cursorCol int
+}
+
+// State is a snapshot of the full editing state of a PromptModel, as returned
+// by Snapshot and accepted by Restore. All fields are exported, so that the
+// caller can serialize it, e.g., for crash recovery.
+type State struct {
+	// Lines holds the input lines.
+	Lines []string
+
+	// CursorRow is the zero-based row of the cursor.
+	CursorRow int
+
+	// CursorCol is the zero-based rune column of the cursor.
+	CursorCol int
+
+	// History holds all history entries, oldest first.
+	History []string
+
+	// Output is the displayed output of the last executed command.
+	Output string
 }
 
 // NewPromptModel creates a new prompt model instance with the given
@@ -224,8 +1007,26 @@ type PromptModel struct {
 // defaults are applied. Returns a pointer suitable for use with
 // bubbletea.NewProgram.
 func NewPromptModel(config PromptConfig) *PromptModel {
-	// Ensure default functions are set if the user provided nil.
-	if config.IsCompleteFn == nil {
+	// Ensure default functions are set if the user provided nil. The
+	// completeness check defaults to the configured terminator, if any,
+	// which also replaces an explicitly set DefaultIsComplete.
+	switch {
+	case config.IsCompleteFn != nil &&
+		!isDefaultIsComplete(config.IsCompleteFn):
+
+	case config.Terminator == "/" || config.Terminator != "" &&
+		config.TerminatorOnOwnLine:
+
+		config.IsCompleteFn = IsCompleteWithTerminatorLine(
+			config.Terminator,
+		)
+
+	case config.Terminator != "":
+		config.IsCompleteFn = IsCompleteWithTerminator(
+			config.Terminator,
+		)
+
+	default:
 		config.IsCompleteFn = DefaultIsComplete
 	}
 
@@ -233,6 +1034,19 @@ func NewPromptModel(config PromptConfig) *PromptModel {
 		config.IsWordCharFn = DefaultIsWordChar
 	}
 
+	// Ignore trailing line comments in the completeness check if
+	// configured.
+	if config.StripCommentsForComplete {
+		prefix := config.CommentPrefix
+		if prefix == "" {
+			prefix = "--"
+		}
+
+		config.IsCompleteFn = IsCompleteIgnoringComments(
+			prefix, config.IsCompleteFn,
+		)
+	}
+
 	// Ensure styles are initialized (basic check: see if a core style is
 	// uninitialized).
 	if config.Styles.Prompt.GetForeground() == (lipgloss.NoColor{}) {
@@ -245,14 +1059,30 @@ func NewPromptModel(config PromptConfig) *PromptModel {
 		config.PopupMaxHeight = 6
 	}
 
-	return &PromptModel{
+	// Mask input with a bullet by default.
+	if config.MaskRune == 0 {
+		config.MaskRune = '•'
+	}
+
+	// Fall back to in-memory history if no store was provided.
+	if config.History == nil {
+		config.History = newSliceHistory()
+	}
+
+	m := &PromptModel{
 		config:       config,
 		lines:        []string{""},
 		cursorRow:    0,
 		cursorCol:    0,
-		history:      []string{},
+		history:      config.History,
 		historyIndex: -1,
 	}
+
+	// The prompt starts out empty and focused, so show the seeded
+	// suggestions right away if configured.
+	m.showSuggestionsOnEmpty()
+
+	return m
 }
 
 // Init initializes the PromptModel. Currently, it performs no initial actions.
@@ -270,30 +1100,121 @@ func (m *PromptModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
 	// Handle key press messages.
 	case tea.KeyMsg:
-		// Delegate key press handling to a dedicated method. Pass the
-		// pointer receiver (*m) because handlers modify the model.
-		return m.handleKeyPress(msg)
+		return m.handleKeyMsg(msg, time.Now())
+
+	// Handle the idle timer expiring.
+	case idleTickMsg:
+		m.handleIdleTick(msg)
+		return m, nil
+
+	// Handle the complete result of an asynchronous execution.
+	case OutputMsg:
+		m.setAsyncOutput(string(msg))
+		return m, nil
+
+	// Handle a fragment of streamed output.
+	case OutputChunkMsg:
+		m.appendOutputChunk(string(msg))
+		return m, nil
 
-		// Handle other message types (e.g., window resize) if needed in
-		// the future.
-		// case tea.WindowSizeMsg:
-		//     // Example: m.handleResize(msg)
-		//     return m, nil
+	// Handle the end of a streamed output sequence.
+	case OutputDoneMsg:
+		m.finishOutputStream()
+		return m, nil
+
+	// Handle terminal resizes by remembering the new width.
+	case tea.WindowSizeMsg:
+		m.width = msg.Width
+		return m, nil
 	}
 
 	// If the message type is not handled, return the model unchanged.
 	return m, nil
 }
 
+// handleKeyMsg handles a key press received at the given time. Besides
+// dispatching it to handleKeyPress, it records the key press for replay and
+// reports and records any edit it made for undo.
+func (m *PromptModel) handleKeyMsg(msg tea.KeyMsg,
+	now time.Time) (tea.Model, tea.Cmd) {
+
+	// Record the key press for later replay if configured. Masked input
+	// and replayed key presses are never recorded.
+	if m.config.RecordKeys && !m.config.MaskInput && !m.replaying {
+		m.recordedKeys = append(m.recordedKeys, msg)
+	}
+
+	// Start tracking edits. The edit helpers mark the input as edited
+	// and take the undo snapshot only when they change it.
+	m.keyTime = now
+	m.edited = false
+	m.undoBefore = nil
+	m.eventEmitted = false
+	m.undoReset = false
+
+	// Delegate key press handling to a dedicated method. Pass the
+	// pointer receiver (*m) because handlers modify the model.
+	model, cmd := m.handleKeyPress(msg)
+	m.notifyEdit()
+
+	// Record the edit for undo, unless it was an undo itself.
+	if msg.Type != tea.KeyCtrlZ {
+		m.recordUndo(now)
+	}
+
+	// Restart the idle timer after every key press.
+	return model, tea.Batch(cmd, m.scheduleIdleTick())
+}
+
 // handleKeyPress acts as the central dispatcher for key press events. It routes
 // the key press to more specific handler methods based on the key type.
 func (m *PromptModel) handleKeyPress(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	// Guard against an empty input or an out-of-bounds cursor, e.g., after
+	// programmatic changes, before any handler indexes the input.
+	m.clampCursor()
+
+	// Ignore all input while disabled, but still allow quitting.
+	if m.disabled {
+		if msg.Type == tea.KeyCtrlC {
+			return m, tea.Quit
+		}
+		return m, nil
+	}
+
 	// Clear the output from the previous command as soon as the user
 	// interacts again (except when pressing Enter to potentially submit).
 	if msg.Type != tea.KeyEnter {
 		m.clearLastOutputOnEdit(msg.Type)
 	}
 
+	// In block selection mode, editing keys apply to the whole block.
+	if m.blockMode && m.handleBlockKey(msg) {
+		return m, nil
+	}
+
+	// With multiple cursors, editing keys apply to all of them. In Vi
+	// normal mode, typed characters are commands instead, which are left
+	// to the Vi key handling below.
+	if len(m.extraCursors) > 0 && m.viState == viInsert &&
+		m.handleMultiCursorKey(msg) {
+
+		return m, nil
+	}
+
+	// Insert the text produced by a macro bound to the key, if any.
+	if macro, ok := m.config.MacroKeys[msg.String()]; ok {
+		m.insertText(macro())
+		m.updateAutocomplete()
+		return m, nil
+	}
+
+	// In Vi mode, keys are first dispatched based on the Vi state.
+	if m.config.ViMode {
+		if handled, cmd := m.handleViKey(msg); handled {
+			return m, cmd
+		}
+	}
+
 	// Dispatch based on the specific key type for reliable handling.
 	switch msg.Type {
 	case tea.KeyCtrlC, tea.KeyEsc:
@@ -301,9 +1222,15 @@ func (m *PromptModel) handleKeyPress(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		return m, tea.Quit
 
 	case tea.KeyEnter:
+		// When Tab cycles through the suggestions, Enter accepts the
+		// selected one.
+		if m.config.TabCycles && m.showPopup {
+			m.applyAutocomplete()
+			return m, nil
+		}
+
 		// Handle command submission or newline insertion.
-		m.handleEnter()
-		return m, nil
+		return m, m.handleEnter()
 
 	case tea.KeyBackspace:
 		// Handle character deletion or line merging.
@@ -312,11 +1239,31 @@ func (m *PromptModel) handleKeyPress(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		m.updateAutocomplete()
 		return m, nil
 
+	case tea.KeyDelete:
+		// Handle forward deletion of the character under the cursor.
+		m.deleteAtCursor()
+		// Update autocomplete suggestions based on the change.
+		m.updateAutocomplete()
+		return m, nil
+
+	case tea.KeyCtrlD:
+		// Handle end-of-input on an empty buffer or forward deletion.
+		return m, m.handleCtrlD()
+
 	case tea.KeyTab:
 		// Handle attempt to apply the selected autocomplete suggestion.
 		m.handleAutocompleteTab()
 		return m, nil
 
+	case tea.KeyShiftTab:
+		// Handle cycling backwards through the suggestions. Otherwise
+		// the key isn't used by the prompt.
+		if !m.config.TabCycles || !m.showPopup {
+			return m.handleUnhandledKey(msg)
+		}
+		m.navigateAutocompleteUp()
+		return m, nil
+
 	case tea.KeyUp:
 		// Handle moving cursor up, navigating history, or suggestion
 		// list.
@@ -329,19 +1276,76 @@ func (m *PromptModel) handleKeyPress(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		m.handleDownArrow()
 		return m, nil
 
+	case tea.KeyPgUp:
+		// Handle paging up through the suggestion list.
+		m.navigateAutocompletePageUp()
+		return m, nil
+
+	case tea.KeyPgDown:
+		// Handle paging down through the suggestion list.
+		m.navigateAutocompletePageDown()
+		return m, nil
+
 	case tea.KeyLeft:
 		// Handle moving cursor left.
 		m.moveCursorLeft()
-		// Clear suggestions as horizontal movement usually cancels
-		// completion intent.
-		m.clearAutocomplete()
+		// Clear or refresh suggestions as configured.
+		m.afterHorizontalMove()
 		return m, nil
 
 	case tea.KeyRight:
 		// Handle moving cursor right.
 		m.moveCursorRight()
-		// Clear suggestions as horizontal movement usually cancels
-		// completion intent.
+		// Clear or refresh suggestions as configured.
+		m.afterHorizontalMove()
+		return m, nil
+
+	case tea.KeyShiftUp:
+		// Handle scrolling clipped output up.
+		m.scrollOutput(-1)
+		return m, nil
+
+	case tea.KeyShiftDown:
+		// Handle scrolling clipped output down.
+		m.scrollOutput(1)
+		return m, nil
+
+	case tea.KeyCtrlAt:
+		// Handle an explicit completion request (Ctrl+Space).
+		m.TriggerComplete()
+		return m, nil
+
+	case tea.KeyCtrlB:
+		// Handle starting a rectangular block selection.
+		m.toggleBlockMode()
+		return m, nil
+
+	case tea.KeyCtrlZ:
+		// Handle reverting the most recent group of edits.
+		m.Undo()
+		return m, nil
+
+	case tea.KeyCtrlUnderscore:
+		// Handle toggling suggestion descriptions (Ctrl+/, which most
+		// terminals send as Ctrl+_).
+		m.ToggleDescription()
+		return m, nil
+
+	case tea.KeyCtrlDown:
+		// Handle adding a cursor on the line below for multi-cursor
+		// editing.
+		m.addCursorBelow()
+		return m, nil
+
+	case tea.KeyCtrlHome:
+		// Handle jumping to the very start of the input.
+		m.moveCursorToStart()
+		m.clearAutocomplete()
+		return m, nil
+
+	case tea.KeyCtrlEnd:
+		// Handle jumping to the very end of the input.
+		m.moveCursorToEnd()
 		m.clearAutocomplete()
 		return m, nil
 
@@ -361,76 +1365,333 @@ func (m *PromptModel) handleKeyPress(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		return m, nil
 
 	default:
-		// Ignore any other key types not explicitly handled.
-		return m, nil
+		// Pass any other key types not explicitly handled on.
+		return m.handleUnhandledKey(msg)
 	}
 }
 
-// clearLastOutputOnEdit clears the display area for the previous command's
-// output if the pressed key indicates editing or significant navigation is
-// occurring.
-func (m *PromptModel) clearLastOutputOnEdit(keyType tea.KeyType) {
-	switch keyType {
-	// List of key types that trigger clearing the output.
-	case tea.KeyBackspace, tea.KeyRunes, tea.KeySpace,
-		tea.KeyUp, tea.KeyDown, tea.KeyLeft, tea.KeyRight:
-		// Reset the lastOutput field.
-		m.lastOutput = ""
+// handleUnhandledKey passes a key press the prompt doesn't handle itself to
+// the configured OnUnhandledKey function, if any, and ignores it otherwise.
+func (m *PromptModel) handleUnhandledKey(msg tea.KeyMsg) (tea.Model,
+	tea.Cmd) {
+
+	if m.config.OnUnhandledKey != nil {
+		return m, m.config.OnUnhandledKey(msg)
 	}
+	return m, nil
 }
 
-// insertCharacter is a convenience method to insert a single character rune.
-func (m *PromptModel) insertCharacter(r rune) {
-	m.insertRunes([]rune{r})
-}
+// scheduleIdleTick records a key press and returns a command delivering an
+// idleTickMsg after the configured IdleTimeout. It returns nil if no idle
+// callback is configured or the input is masked.
+func (m *PromptModel) scheduleIdleTick() tea.Cmd {
+	m.keySeq++
 
-// insertRunes inserts a slice of printable characters (runes) at the cursor
-// position. It filters out non-printable runes and resets history Browse mode.
-func (m *PromptModel) insertRunes(runes []rune) {
-	// Filter out potential control characters that might slip through as
-	// runes.
-	printableRunes := []rune{}
-	for _, r := range runes {
-		// Basic check for printable range (includes space).
-		if r >= ' ' {
-			printableRunes = append(printableRunes, r)
-		}
+	if m.config.OnIdle == nil || m.config.IdleTimeout <= 0 ||
+		m.config.MaskInput {
+
+		return nil
 	}
 
-	// Only proceed if there are actual printable runes to insert.
-	if len(printableRunes) == 0 {
+	seq := m.keySeq
+	return tea.Tick(m.config.IdleTimeout, func(time.Time) tea.Msg {
+		return idleTickMsg{seq: seq}
+	})
+}
+
+// handleIdleTick calls the configured OnIdle function if no key was pressed
+// since the tick was scheduled.
+func (m *PromptModel) handleIdleTick(msg idleTickMsg) {
+	// A newer key press has rescheduled the timer, ignore this tick.
+	if msg.seq != m.keySeq || m.config.OnIdle == nil ||
+		m.config.MaskInput {
+
 		return
 	}
 
-	// Get the current line where the cursor is.
-	line := m.lines[m.cursorRow]
+	m.config.OnIdle(m.getCurrentInput())
+}
 
-	// Reconstruct the line with the new runes inserted at the cursor
-	// column.
-	m.lines[m.cursorRow] = line[:m.cursorCol] + string(printableRunes) +
-		line[m.cursorCol:]
+// appendOutputChunk appends a fragment of streamed output to the displayed
+// output and marks the output as streaming.
+func (m *PromptModel) appendOutputChunk(chunk string) {
+	m.lastOutput += chunk
+	m.streaming = true
+}
 
-	// Move the cursor forward by the number of runes inserted.
-	// Note: Using len() is okay here as we inserted printable runes.
-	m.cursorCol += len(printableRunes)
+// finishOutputStream finalizes a stream of output chunks. The accumulated
+// output stays visible until the next edit clears it.
+func (m *PromptModel) finishOutputStream() {
+	// Acknowledge a stream that produced no output if configured.
+	m.lastOutput = m.emptyOutputPlaceholder(m.lastOutput)
 
-	// If the user types anything, they are no longer Browse history.
-	m.historyIndex = -1
+	// Append the execution time of the streamed output if configured.
+	if timing := m.asyncTiming(); timing != "" {
+		if !strings.HasSuffix(m.lastOutput, "\n") {
+			m.lastOutput += "\n"
+		}
+		m.lastOutput += timing
+	}
+
+	m.streaming = false
+	m.busy = false
+	m.notifyOutputComplete()
 }
 
-// deleteBeforeCursor handles the Backspace key logic: deleting a character
-// or merging the current line with the previous one if at the start of a line.
+// setAsyncOutput displays the complete result of an asynchronous execution,
+// replacing any previous output, and finalizes it.
+func (m *PromptModel) setAsyncOutput(output string) {
+	output = m.emptyOutputPlaceholder(output)
+
+	// Append the execution time if configured.
+	if timing := m.asyncTiming(); timing != "" {
+		output += "\n" + timing
+	}
+
+	m.setOutput(formatOutput(output))
+	m.streaming = false
+	m.busy = false
+	m.notifyOutputComplete()
+}
+
+// emptyOutputPlaceholder returns the configured EmptyOutputText in place of an
+// execution output consisting only of whitespace. Any other output is returned
+// unchanged.
+func (m PromptModel) emptyOutputPlaceholder(output string) string {
+	if strings.TrimSpace(output) == "" && m.config.EmptyOutputText != "" {
+		return m.config.EmptyOutputText
+	}
+
+	return output
+}
+
+// asyncTiming returns the formatted time since the last asynchronous execution
+// was dispatched and resets the start time. It returns an empty string if
+// ShowTiming isn't set or no asynchronous execution is pending.
+func (m *PromptModel) asyncTiming() string {
+	if m.execStart.IsZero() {
+		return ""
+	}
+
+	elapsed := time.Since(m.execStart)
+	m.execStart = time.Time{}
+
+	if !m.config.ShowTiming {
+		return ""
+	}
+
+	return formatTiming(elapsed)
+}
+
+// formatTiming formats an execution duration for display after the output.
+func formatTiming(d time.Duration) string {
+	return fmt.Sprintf("(took %dms)", d.Milliseconds())
+}
+
+// setOutput replaces the displayed output and scrolls it back to the top.
+func (m *PromptModel) setOutput(output string) {
+	m.lastOutput = output
+	m.outputScrollOffset = 0
+}
+
+// outputLines returns the lines of the displayed output, without trailing
+// newlines to prevent double spacing.
+func (m PromptModel) outputLines() []string {
+	output := strings.TrimRight(m.lastOutput, "\n")
+
+	// Word wrap long lines to the available width if configured. Embedded
+	// newlines are preserved.
+	if width := m.outputWidth(); m.config.WrapOutput && width > 0 {
+		output = ansi.WrapWc(output, width, "")
+	}
+
+	return strings.Split(output, "\n")
+}
+
+// outputWidth returns the width available for each output line, leaving room
+// for the prompt prefix if PromptOutputLines is set. Zero means the width is
+// unknown.
+func (m PromptModel) outputWidth() int {
+	width := m.renderWidth()
+	if width <= 0 {
+		return 0
+	}
+
+	if m.config.PromptOutputLines {
+		_, prompt := splitPrompt(m.config.PromptPrimary)
+		width -= ansi.StringWidthWc(prompt)
+	}
+
+	return max(1, width)
+}
+
+// scrollOutput moves the visible window of output clipped to OutputMaxHeight
+// by delta lines, clamping it to the output bounds.
+func (m *PromptModel) scrollOutput(delta int) {
+	if m.config.OutputMaxHeight <= 0 || m.lastOutput == "" {
+		return
+	}
+
+	maxOffset := max(0, len(m.outputLines())-m.config.OutputMaxHeight)
+	m.outputScrollOffset = max(
+		0, min(maxOffset, m.outputScrollOffset+delta),
+	)
+}
+
+// notifyOutputComplete calls the configured OnOutputComplete function, if any.
+func (m *PromptModel) notifyOutputComplete() {
+	if m.config.OnOutputComplete != nil {
+		m.config.OnOutputComplete()
+	}
+}
+
+// formatOutput formats the result of an execution for display in the View.
+func formatOutput(output string) string {
+	return fmt.Sprintf(
+		"\n--- Executing ---\n%s\n-----------------\n", output,
+	)
+}
+
+// clearLastOutputOnEdit clears the display area for the previous command's
+// output if the pressed key indicates editing or significant navigation is
+// occurring. Output that is still being streamed is never cleared.
+func (m *PromptModel) clearLastOutputOnEdit(keyType tea.KeyType) {
+	// Keep partially streamed output visible until it is finalized.
+	if m.streaming {
+		return
+	}
+
+	switch keyType {
+	// List of key types that trigger clearing the output.
+	case tea.KeyBackspace, tea.KeyRunes, tea.KeySpace,
+		tea.KeyUp, tea.KeyDown, tea.KeyLeft, tea.KeyRight,
+		tea.KeyDelete, tea.KeyCtrlD, tea.KeyCtrlHome, tea.KeyCtrlEnd:
+		// Reset the lastOutput field.
+		m.setOutput("")
+	}
+}
+
+// insertCharacter is a convenience method to insert a single character rune.
+func (m *PromptModel) insertCharacter(r rune) {
+	m.insertRunes([]rune{r})
+}
+
+// filterControlChars applies the configured ControlCharPolicy to the given
+// runes. Newlines and tabs are always dropped, regardless of the policy.
+func (m *PromptModel) filterControlChars(runes []rune) []rune {
+	printableRunes := []rune{}
+	for _, r := range runes {
+		// Basic check for printable range (includes space).
+		if r >= ' ' {
+			printableRunes = append(printableRunes, r)
+			continue
+		}
+
+		// Newlines and tabs are not subject to the policy.
+		if r == '\n' || r == '\t' {
+			continue
+		}
+
+		switch m.config.ControlChars {
+		case ControlCharEscape:
+			// Render the control character in caret notation,
+			// e.g., 0x01 becomes "^A".
+			printableRunes = append(printableRunes, '^', r+'@')
+
+		case ControlCharKeep:
+			// Insert the control character unchanged.
+			printableRunes = append(printableRunes, r)
+		}
+	}
+
+	return printableRunes
+}
+
+// applyCaseTransform converts the letters in the given runes in place according
+// to the configured CaseTransform. Typed and pasted input are treated alike.
+func (m *PromptModel) applyCaseTransform(runes []rune) {
+	for i, r := range runes {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+
+		switch m.config.CaseTransform {
+		case CaseUpper:
+			runes[i] = unicode.ToUpper(r)
+
+		case CaseLower:
+			runes[i] = unicode.ToLower(r)
+		}
+	}
+}
+
+// insertRunes inserts a slice of printable characters (runes) at the cursor
+// position. It handles control characters according to the configured
+// ControlCharPolicy and resets history Browse mode.
+func (m *PromptModel) insertRunes(runes []rune) {
+	// Filter out or transform potential control characters that might
+	// slip through as runes.
+	printableRunes := m.filterControlChars(runes)
+
+	// Only proceed if there are actual printable runes to insert.
+	if len(printableRunes) == 0 {
+		return
+	}
+
+	// Convert letters to the configured case, if any.
+	m.applyCaseTransform(printableRunes)
+
+	// Get the current line where the cursor is and the byte offset of
+	// the cursor column within it.
+	line := m.lines[m.cursorRow]
+	offset := runeOffset(line, m.cursorCol)
+
+	// Reconstruct the line with the new runes inserted at the cursor
+	// column.
+	m.markEdited()
+	m.lines[m.cursorRow] = line[:offset] + string(printableRunes) +
+		line[offset:]
+
+	// Move the cursor forward by the number of runes inserted, keeping
+	// it within the line bounds.
+	m.cursorCol = utf8.RuneCountInString(line[:offset]) +
+		len(printableRunes)
+
+	// If the user types anything, they are no longer Browse history.
+	m.historyIndex = -1
+}
+
+// insertText inserts the given text at the cursor position, splitting it into
+// multiple lines at newlines.
+func (m *PromptModel) insertText(text string) {
+	for i, part := range strings.Split(text, "\n") {
+		if i > 0 {
+			m.insertNewline()
+		}
+
+		m.insertRunes([]rune(part))
+	}
+}
+
+// deleteBeforeCursor handles the Backspace key logic: deleting a character
+// or merging the current line with the previous one if at the start of a line.
 func (m *PromptModel) deleteBeforeCursor() {
 	if m.cursorCol > 0 {
 		// Case 1: Cursor is not at the beginning of the line.
-		// Delete the character immediately before the cursor.
+		// Delete the grapheme cluster (user-perceived character, e.g.,
+		// an emoji with a skin tone modifier) immediately before the
+		// cursor. Work on byte offsets to avoid converting the whole
+		// line to runes.
 		line := m.lines[m.cursorRow]
+		offset := runeOffset(line, m.cursorCol)
+		start := lastGraphemeStart(line[:offset])
 
-		// Reconstruct the line without the character at cursorCol-1.
-		m.lines[m.cursorRow] = line[:m.cursorCol-1] + line[m.cursorCol:]
+		// Reconstruct the line without the cluster before the cursor.
+		m.markEdited()
+		m.lines[m.cursorRow] = line[:start] + line[offset:]
 
-		// Move the cursor back one position.
-		m.cursorCol--
+		// Move the cursor back to the start of the deleted cluster.
+		m.cursorCol = utf8.RuneCountInString(line[:start])
 	} else if m.cursorRow > 0 {
 		// Case 2: Cursor is at the beginning of a line (but not the
 		// first line). Merge this line with the previous line.
@@ -438,9 +1699,10 @@ func (m *PromptModel) deleteBeforeCursor() {
 		currentLine := m.lines[m.cursorRow]
 
 		// Store the target cursor column (end of the previous line).
-		targetCol := len(prevLine)
+		targetCol := utf8.RuneCountInString(prevLine)
 
 		// Append the current line's content to the previous line.
+		m.markEdited()
 		m.lines[m.cursorRow-1] += currentLine
 
 		// Remove the current line from the slice by slicing around it.
@@ -455,19 +1717,118 @@ func (m *PromptModel) deleteBeforeCursor() {
 		m.cursorCol = targetCol
 	}
 	// If cursorRow is 0 and cursorCol is 0, Backspace does nothing.
+
+	// Never leave the input without any lines.
+	m.ensureNonEmpty()
+}
+
+// deleteAtCursor handles the Delete key logic: deleting the character under
+// the cursor or merging the next line into the current one if at the end of a
+// line.
+func (m *PromptModel) deleteAtCursor() {
+	// Work on byte offsets to avoid converting the whole line to runes.
+	line := m.lines[m.cursorRow]
+	offset := runeOffset(line, m.cursorCol)
+
+	if offset < len(line) {
+		// Case 1: Cursor is not at the end of the line. Delete the
+		// grapheme cluster under the cursor.
+		clusterSize := firstGraphemeSize(line[offset:])
+		m.markEdited()
+		m.lines[m.cursorRow] = line[:offset] +
+			line[offset+clusterSize:]
+	} else if m.cursorRow < len(m.lines)-1 {
+		// Case 2: Cursor is at the end of a line (but not the last
+		// line). Merge the next line into this one.
+		m.markEdited()
+		m.lines[m.cursorRow] += m.lines[m.cursorRow+1]
+
+		// Remove the next line from the slice by slicing around it.
+		m.lines = append(
+			m.lines[:m.cursorRow+1], m.lines[m.cursorRow+2:]...,
+		)
+	}
+	// If the cursor is at the end of the last line, Delete does nothing.
+
+	// The cursor stays in place, but keep it within the line bounds.
+	m.cursorCol = utf8.RuneCountInString(line[:offset])
+}
+
+// firstGraphemeSize returns the number of bytes making up the first grapheme
+// cluster in the given string. It returns zero for an empty string.
+func firstGraphemeSize(s string) int {
+	cluster, _, _, _ := uniseg.FirstGraphemeClusterInString(s, -1)
+
+	return len(cluster)
+}
+
+// handleCtrlD implements shell-like Ctrl+D behavior. On an empty buffer it
+// signals end-of-input by calling the configured OnEOF function or quitting if
+// none is set. Otherwise, it deletes the character under the cursor.
+func (m *PromptModel) handleCtrlD() tea.Cmd {
+	if len(m.lines) == 1 && m.lines[0] == "" {
+		// Let the user decide how to handle end-of-input.
+		if m.config.OnEOF != nil {
+			return m.config.OnEOF()
+		}
+
+		return tea.Quit
+	}
+
+	// Behave like the Delete key on a non-empty buffer.
+	m.deleteAtCursor()
+	m.updateAutocomplete()
+
+	return nil
+}
+
+// lastGraphemeStart returns the byte offset at which the last grapheme cluster
+// in the given string starts. It returns zero for an empty string.
+func lastGraphemeStart(s string) int {
+	start := 0
+
+	// Walk through all clusters without allocating, remembering where the
+	// last one starts.
+	rest, state := s, -1
+	for rest != "" {
+		var cluster string
+		cluster, rest, _, state = uniseg.FirstGraphemeClusterInString(
+			rest, state,
+		)
+		start = len(s) - len(rest) - len(cluster)
+	}
+
+	return start
+}
+
+// runeOffset returns the byte offset of the rune at the given column of the
+// line, or the length of the line if the column is beyond its end. Unlike
+// converting the whole line to runes, it only walks the line up to the column
+// and doesn't allocate.
+func runeOffset(line string, col int) int {
+	for offset := range line {
+		if col <= 0 {
+			return offset
+		}
+		col--
+	}
+
+	return len(line)
 }
 
 // insertNewline handles inserting a newline character. It splits the current
 // line at the cursor position into two lines.
 func (m *PromptModel) insertNewline() {
-	// Get the content of the current line.
+	// Get the content of the current line and the byte offset of the
+	// cursor column within it.
 	currentLine := m.lines[m.cursorRow]
+	offset := runeOffset(currentLine, m.cursorCol)
 
 	// Get the part of the line before the cursor.
-	left := currentLine[:m.cursorCol]
+	left := currentLine[:offset]
 
 	// Get the part of the line at and after the cursor.
-	right := currentLine[m.cursorCol:]
+	right := currentLine[offset:]
 
 	// Construct the new slice of lines.
 	// 1. Copy all lines before the current row.
@@ -485,6 +1846,7 @@ func (m *PromptModel) insertNewline() {
 	}
 
 	// Replace the lines slice.
+	m.markEdited()
 	m.lines = newLines
 	// Move the cursor down to the newly created line ('right' part).
 	m.cursorRow++
@@ -494,19 +1856,31 @@ func (m *PromptModel) insertNewline() {
 	// Clean up any potential extra blank lines created at the end.
 	m.cleanupExtraBlankLines()
 
+	// Safety check: If all lines were somehow removed, reset to a single
+	// empty line.
+	m.ensureNonEmpty()
+
 	// Ensure the cursor row index is still valid after potential cleanup.
 	// (Cleanup might remove the line the cursor just moved to).
-	if m.cursorRow >= len(m.lines) && len(m.lines) > 0 {
+	if m.cursorRow >= len(m.lines) {
 		// Move cursor to the new last line.
 		m.cursorRow = len(m.lines) - 1
-	} else if len(m.lines) == 0 {
-		// Safety check: If all lines were somehow removed, reset to a
-		// single empty line.
-		m.lines = []string{""}
-		m.cursorRow = 0
 	}
 }
 
+// ensureNonEmpty restores a single empty line and moves the cursor to its
+// start if the input lines have become empty. This guarantees that indexing
+// the line under the cursor never panics.
+func (m *PromptModel) ensureNonEmpty() {
+	if len(m.lines) > 0 {
+		return
+	}
+
+	m.lines = []string{""}
+	m.cursorRow = 0
+	m.cursorCol = 0
+}
+
 // moveCursorUp moves the cursor up one line. If the target line is shorter than
 // the current column, it snaps the cursor to the end of that line.
 func (m *PromptModel) moveCursorUp() {
@@ -515,10 +1889,8 @@ func (m *PromptModel) moveCursorUp() {
 		m.cursorRow--
 
 		// Check if the target column position exists on the new line.
-		if m.cursorCol > len(m.lines[m.cursorRow]) {
-			// If not, move cursor to the end of the shorter line.
-			m.cursorCol = len(m.lines[m.cursorRow])
-		}
+		// If not, move cursor to the end of the shorter line.
+		m.cursorCol = min(m.cursorCol, m.lineLen(m.cursorRow))
 	}
 }
 
@@ -530,10 +1902,8 @@ func (m *PromptModel) moveCursorDown() {
 		m.cursorRow++
 
 		// Check if the target column position exists on the new line.
-		if m.cursorCol > len(m.lines[m.cursorRow]) {
-			// If not, move cursor to the end of the shorter line.
-			m.cursorCol = len(m.lines[m.cursorRow])
-		}
+		// If not, move cursor to the end of the shorter line.
+		m.cursorCol = min(m.cursorCol, m.lineLen(m.cursorRow))
 	}
 }
 
@@ -548,7 +1918,7 @@ func (m *PromptModel) moveCursorLeft() {
 		// the previous line.
 		m.cursorRow--
 		// Position cursor at the end of the previous line.
-		m.cursorCol = len(m.lines[m.cursorRow])
+		m.cursorCol = m.lineLen(m.cursorRow)
 	}
 }
 
@@ -556,7 +1926,7 @@ func (m *PromptModel) moveCursorLeft() {
 // (and not the last line), it wraps to the beginning of the next line.
 func (m *PromptModel) moveCursorRight() {
 	// If not at the end of the current line, simply move right.
-	if m.cursorCol < len(m.lines[m.cursorRow]) {
+	if m.cursorCol < m.lineLen(m.cursorRow) {
 		m.cursorCol++
 	} else if m.cursorRow < len(m.lines)-1 {
 		// If at the end of a line (but not the last), wrap to the next
@@ -567,6 +1937,33 @@ func (m *PromptModel) moveCursorRight() {
 	}
 }
 
+// moveCursorToStart moves the cursor to the beginning of the first line.
+func (m *PromptModel) moveCursorToStart() {
+	m.cursorRow = 0
+	m.cursorCol = 0
+}
+
+// moveCursorToEnd moves the cursor to the end of the last line.
+func (m *PromptModel) moveCursorToEnd() {
+	m.cursorRow = len(m.lines) - 1
+	m.cursorCol = m.lineLen(m.cursorRow)
+}
+
+// lineLen returns the length of the input line at the given row in runes,
+// which is the unit of the cursor column.
+func (m PromptModel) lineLen(row int) int {
+	return utf8.RuneCountInString(m.lines[row])
+}
+
+// clampCursor moves the cursor into the bounds of the input, should it ever
+// point past the end of its line or beyond the last line.
+func (m *PromptModel) clampCursor() {
+	m.ensureNonEmpty()
+
+	m.cursorRow = max(0, min(m.cursorRow, len(m.lines)-1))
+	m.cursorCol = max(0, min(m.cursorCol, m.lineLen(m.cursorRow)))
+}
+
 // getTextBeforeCursor returns all text from the beginning of the input up to
 // the current cursor position, joining lines with newlines. This is used to
 // provide context to the AutoCompleteFunc.
@@ -602,9 +1999,135 @@ func (m *PromptModel) getTextBeforeCursor() string {
 	return sb.String()
 }
 
+// TextAfterCursor returns all text from the current cursor position to the end
+// of the input, joining lines with newlines. It is the complement of the text
+// before the cursor passed to the AutoCompleteFunc and can be used by
+// completers that consider the following context.
+func (m *PromptModel) TextAfterCursor() string {
+	// Safety check for cursor row bounds.
+	if m.cursorRow < 0 || m.cursorRow >= len(m.lines) {
+		return ""
+	}
+
+	var sb strings.Builder
+
+	// Append the part of the current line *after* the cursor column. Use
+	// runes for slicing to handle multi-byte characters correctly.
+	runes := []rune(m.lines[m.cursorRow])
+	col := max(0, min(m.cursorCol, len(runes)))
+	sb.WriteString(string(runes[col:]))
+
+	// Append all lines *after* the current cursor row.
+	for i := m.cursorRow + 1; i < len(m.lines); i++ {
+		// Add newline separator between lines.
+		sb.WriteRune('\n')
+
+		sb.WriteString(m.lines[i])
+	}
+
+	return sb.String()
+}
+
 // updateAutocomplete checks the context around the cursor and calls the
 // configured AutoCompleteFunc if appropriate, updating the suggestion state.
+// With ManualCompleteOnly set, only an already open popup is updated.
 func (m *PromptModel) updateAutocomplete() {
+	// Never show suggestions while autocompletion is disabled.
+	if m.completionDisabled() {
+		m.clearAutocomplete()
+		return
+	}
+
+	// Don't open the popup automatically if completion must be triggered
+	// explicitly.
+	if m.config.ManualCompleteOnly && !m.showPopup {
+		m.clearAutocomplete()
+		return
+	}
+
+	m.refreshAutocomplete()
+}
+
+// afterHorizontalMove updates the suggestion state after the cursor moved left
+// or right. By default, suggestions are cleared as horizontal movement usually
+// cancels completion intent. With KeepPopupOnHorizontalMove, they are
+// refreshed for the word fragment at the new cursor position instead.
+func (m *PromptModel) afterHorizontalMove() {
+	if m.config.KeepPopupOnHorizontalMove {
+		m.updateAutocomplete()
+		return
+	}
+
+	m.clearAutocomplete()
+}
+
+// ToggleDescription flips ShowDescription, showing or hiding the descriptions
+// of suggestions while the prompt is running. It is bound to Ctrl+/.
+func (m *PromptModel) ToggleDescription() {
+	m.config.ShowDescription = !m.config.ShowDescription
+
+	// The cached popup widths depend on whether descriptions are shown.
+	m.setSuggestions(m.suggestions)
+}
+
+// TriggerComplete explicitly requests suggestions for the word fragment before
+// the cursor, opening the popup if there are any. This is the only way to open
+// the popup if ManualCompleteOnly is set. It is bound to Ctrl+Space.
+func (m *PromptModel) TriggerComplete() {
+	// Never show suggestions while autocompletion is disabled.
+	if m.completionDisabled() {
+		return
+	}
+
+	// Force fetching fresh suggestions. Explicitly requested seeded
+	// suggestions are navigated like any others.
+	m.lastSuggestedWord = ""
+	m.refreshAutocomplete()
+	m.popupSeeded = false
+}
+
+// showSuggestionsOnEmpty opens the popup with the configured
+// SuggestionsOnEmpty if the buffer is empty and the prompt is focused and
+// enabled. It returns whether the seeded suggestions are shown.
+func (m *PromptModel) showSuggestionsOnEmpty() bool {
+	if len(m.config.SuggestionsOnEmpty) == 0 || m.blurred ||
+		m.disabled || m.completionDisabled() {
+
+		return false
+	}
+
+	if len(m.lines) != 1 || m.lines[0] != "" {
+		return false
+	}
+
+	m.setSuggestions(m.processSuggestions(m.config.SuggestionsOnEmpty))
+	m.selectedSuggestionIndex = 0
+	m.popupScrollOffset = 0
+	m.selectPreferredSuggestion()
+	m.setPopupVisible(len(m.suggestions) > 0)
+
+	// No word fragment generated these suggestions, so that typing
+	// fetches suggestions from the completer.
+	m.lastSuggestedWord = ""
+	m.popupSeeded = m.showPopup
+
+	return m.showPopup
+}
+
+// completionDisabled returns whether autocompletion is turned off, either
+// explicitly or because the input is masked and must not leak to the
+// completer.
+func (m *PromptModel) completionDisabled() bool {
+	return m.autocompleteDisabled || m.config.MaskInput
+}
+
+// refreshAutocomplete checks the context around the cursor and calls the
+// configured completer if the word fragment before the cursor changed,
+// updating the suggestion state.
+func (m *PromptModel) refreshAutocomplete() {
+	// Never index the input with an out-of-bounds cursor.
+	m.clampCursor()
+
 	// Get the function that defines word characters from the config.
 	isWordCharFn := m.config.IsWordCharFn
 
@@ -635,9 +2158,13 @@ func (m *PromptModel) updateAutocomplete() {
 		clear = true
 	}
 
-	// If clearing, reset autocomplete state and return.
+	// If clearing, reset autocomplete state and return. An empty buffer
+	// may show the seeded suggestions instead.
 	if clear {
-		m.clearAutocomplete()
+		if !m.showSuggestionsOnEmpty() {
+			m.clearAutocomplete()
+		}
+
 		return
 	}
 
@@ -650,20 +2177,20 @@ func (m *PromptModel) updateAutocomplete() {
 		// Reset scroll position.
 		m.popupScrollOffset = 0
 
-		// Check if an autocomplete function is configured.
-		if m.config.AutoCompleteFn != nil {
-			// Get the text context before the cursor.
-			textBefore := m.getTextBeforeCursor()
-			// Call the configured function to get suggestions.
-			m.suggestions = m.config.AutoCompleteFn(
-				textBefore, word,
-			)
-		} else {
-			// No function configured, ensure suggestions are empty.
-			m.suggestions = nil
-		}
-		// Show the popup only if suggestions were returned.
-		m.showPopup = len(m.suggestions) > 0
+		// Call the configured completer to get suggestions and
+		// prepare them for display.
+		m.setSuggestions(
+			m.processSuggestions(m.fetchSuggestions(word)),
+		)
+
+		// Select the preferred suggestion, if any.
+		m.selectPreferredSuggestion()
+
+		// Show the popup only if suggestions were returned, unless
+		// the only one is exactly what was typed already.
+		m.setPopupVisible(
+			len(m.suggestions) > 0 && !m.isSoleExactMatch(word),
+		)
 
 		// Store the word fragment that generated these suggestions.
 		m.lastSuggestedWord = word
@@ -671,35 +2198,241 @@ func (m *PromptModel) updateAutocomplete() {
 		// If the word fragment hasn't changed, but there are no
 		// suggestions (e.g., function returned empty list), ensure the
 		// popup is hidden.
-		m.showPopup = false
+		m.setPopupVisible(false)
 	}
 }
 
-// clearAutocomplete hides the suggestion popup and resets related state
-// variables.
-func (m *PromptModel) clearAutocomplete() {
-	// Clear the suggestion slice.
-	m.suggestions = nil
+// setSuggestions replaces the current suggestions. With StablePopupWidth, the
+// widths of all suggestions are measured once here, so that rendering only
+// needs to touch the visible ones.
+func (m *PromptModel) setSuggestions(suggs []Suggestion) {
+	m.suggestions = suggs
+	m.popupSeeded = false
+
+	m.stableWordWidth, m.stableDescWidth = 0, 0
+	if m.config.StablePopupWidth {
+		m.stableWordWidth, m.stableDescWidth = m.measureSuggestions(
+			suggs,
+		)
+	}
+}
 
-	// Hide the popup.
-	m.showPopup = false
+// measureSuggestions returns the maximum display widths of the words and the
+// descriptions of the given suggestions.
+func (m PromptModel) measureSuggestions(suggs []Suggestion) (int, int) {
+	maxWordWidth, maxDescWidth := 0, 0
+	for _, sugg := range suggs {
+		// Use runewidth.StringWidth for accurate width of potentially
+		// wide characters.
+		maxWordWidth = max(
+			maxWordWidth,
+			runewidth.StringWidth(m.suggestionText(sugg)),
+		)
+		maxDescWidth = max(maxDescWidth, m.descriptionWidth(sugg))
+	}
 
-	// Reset selection index.
-	m.selectedSuggestionIndex = 0
+	return maxWordWidth, maxDescWidth
+}
 
-	// Clear the last word fragment.
-	m.lastSuggestedWord = ""
+// suggestionText returns the text of the given suggestion as displayed in the
+// popup, truncated with an ellipsis to SuggestionMaxWidth if configured. The
+// full text is still inserted when the suggestion is applied.
+func (m PromptModel) suggestionText(sugg Suggestion) string {
+	if m.config.SuggestionMaxWidth <= 0 {
+		return sugg.Text
+	}
 
-	// Reset the scroll offset.
-	m.popupScrollOffset = 0
+	return runewidth.Truncate(sugg.Text, m.config.SuggestionMaxWidth, "…")
 }
 
-// navigateAutocompleteUp moves the selection index up within the suggestion
-// list, handling wrapping and adjusting the scroll offset if necessary.
-func (m *PromptModel) navigateAutocompleteUp() {
-	// Only navigate if the popup is shown and suggestions exist.
-	if m.showPopup && len(m.suggestions) > 0 {
-		// Decrement the selected index.
+// isSoleExactMatch reports whether the only suggestion equals the given word
+// fragment, in which case the popup would just be noise. The comparison
+// ignores case if ExactMatchIgnoreCase is set.
+func (m PromptModel) isSoleExactMatch(word string) bool {
+	if len(m.suggestions) != 1 {
+		return false
+	}
+
+	text := m.suggestions[0].Text
+	if m.config.ExactMatchIgnoreCase {
+		return strings.EqualFold(text, word)
+	}
+
+	return text == word
+}
+
+// selectPreferredSuggestion selects the first suggestion marked as Preferred,
+// scrolling the popup so that it is visible. The selection is left unchanged if
+// no suggestion is preferred.
+func (m *PromptModel) selectPreferredSuggestion() {
+	for i, sugg := range m.suggestions {
+		if !sugg.Preferred {
+			continue
+		}
+
+		m.selectedSuggestionIndex = i
+		if i >= m.popupPageSize(0) {
+			m.popupScrollOffset = m.popupPageStart(i)
+		}
+
+		return
+	}
+}
+
+// fetchSuggestions calls the configured completer for the given word fragment
+// and returns its suggestions. AutoCompleteCtxFn takes precedence over
+// CompleterChain, which takes precedence over AutoCompleteFn. If none is
+// configured, no suggestions are returned.
+func (m *PromptModel) fetchSuggestions(word string) []Suggestion {
+	switch {
+	// Prefer the context based completer if configured.
+	case m.config.AutoCompleteCtxFn != nil:
+		return m.config.AutoCompleteCtxFn(m.completionContext(word))
+
+	// Merge the suggestions of all chained completers.
+	case len(m.config.CompleterChain) > 0:
+		return m.fetchChainSuggestions(word)
+
+	// Fall back to the simple completer, passing the text context before
+	// the cursor.
+	case m.config.AutoCompleteFn != nil:
+		return m.config.AutoCompleteFn(m.getTextBeforeCursor(), word)
+
+	// No function configured, ensure suggestions are empty.
+	default:
+		return nil
+	}
+}
+
+// fetchChainSuggestions calls every completer of the CompleterChain for the
+// given word fragment and concatenates their suggestions in chain order, so
+// that earlier completers have a higher priority. Suggestions with a Text
+// already returned by an earlier completer are dropped.
+func (m *PromptModel) fetchChainSuggestions(word string) []Suggestion {
+	textBeforeCursor := m.getTextBeforeCursor()
+
+	var suggs []Suggestion
+	for _, complete := range m.config.CompleterChain {
+		suggs = append(suggs, complete(textBeforeCursor, word)...)
+	}
+
+	return dedupSuggestions(suggs)
+}
+
+// processSuggestions prepares the suggestions returned by the completer for
+// display and navigation, removing duplicates, sorting and limiting them if
+// configured. The completer's slice is never modified.
+func (m *PromptModel) processSuggestions(suggs []Suggestion) []Suggestion {
+	if len(suggs) == 0 {
+		return suggs
+	}
+
+	// Remove suggestions with duplicate Text, keeping the first one.
+	if m.config.DedupSuggestions {
+		suggs = dedupSuggestions(suggs)
+	}
+
+	// Sort a copy of the suggestions so the completer's slice is left
+	// untouched. Sorting is stable to keep the completer's order for
+	// equal items.
+	if m.config.SortSuggestions {
+		less := m.config.SuggestionLess
+		if less == nil {
+			less = DefaultSuggestionLess
+		}
+
+		suggs = append([]Suggestion{}, suggs...)
+		sort.SliceStable(suggs, func(i, j int) bool {
+			return less(suggs[i], suggs[j])
+		})
+	}
+
+	// Keep only the first suggestions. Capping the capacity ensures that
+	// the completer's slice is never appended to.
+	if limit := m.config.MaxSuggestions; limit > 0 && len(suggs) > limit {
+		suggs = suggs[:limit:limit]
+	}
+
+	return suggs
+}
+
+// dedupSuggestions returns a new slice holding the given suggestions without
+// those whose Text duplicates an earlier suggestion's Text. The order of the
+// remaining suggestions is preserved.
+func dedupSuggestions(suggs []Suggestion) []Suggestion {
+	seen := make(map[string]struct{}, len(suggs))
+	deduped := make([]Suggestion, 0, len(suggs))
+
+	for _, sugg := range suggs {
+		// Skip suggestions whose Text was already added.
+		if _, ok := seen[sugg.Text]; ok {
+			continue
+		}
+
+		seen[sugg.Text] = struct{}{}
+		deduped = append(deduped, sugg)
+	}
+
+	return deduped
+}
+
+// completionContext assembles the CompletionContext for the given word
+// fragment from the current model state.
+func (m *PromptModel) completionContext(word string) CompletionContext {
+	return CompletionContext{
+		TextBeforeCursor: m.getTextBeforeCursor(),
+		TextAfterCursor:  m.TextAfterCursor(),
+		WordFragment:     word,
+		CursorRow:        m.cursorRow,
+		CursorCol:        m.cursorCol,
+		// Copy the lines so that the completer can't modify the input.
+		Lines: append([]string{}, m.lines...),
+	}
+}
+
+// setPopupVisible shows or hides the suggestion popup, notifying the
+// configured OnPopupOpen or OnPopupClose function if the visibility changes.
+func (m *PromptModel) setPopupVisible(visible bool) {
+	if visible == m.showPopup {
+		return
+	}
+
+	m.showPopup = visible
+
+	switch {
+	case visible && m.config.OnPopupOpen != nil:
+		m.config.OnPopupOpen()
+
+	case !visible && m.config.OnPopupClose != nil:
+		m.config.OnPopupClose()
+	}
+}
+
+// clearAutocomplete hides the suggestion popup and resets related state
+// variables.
+func (m *PromptModel) clearAutocomplete() {
+	// Clear the suggestion slice.
+	m.setSuggestions(nil)
+
+	// Hide the popup.
+	m.setPopupVisible(false)
+
+	// Reset selection index.
+	m.selectedSuggestionIndex = 0
+
+	// Clear the last word fragment.
+	m.lastSuggestedWord = ""
+
+	// Reset the scroll offset.
+	m.popupScrollOffset = 0
+}
+
+// navigateAutocompleteUp moves the selection index up within the suggestion
+// list, handling wrapping and adjusting the scroll offset if necessary.
+func (m *PromptModel) navigateAutocompleteUp() {
+	// Only navigate if the popup is shown and suggestions exist.
+	if m.showPopup && len(m.suggestions) > 0 {
+		// Decrement the selected index.
 		m.selectedSuggestionIndex--
 
 		// Check if we've moved past the top suggestion.
@@ -708,8 +2441,8 @@ func (m *PromptModel) navigateAutocompleteUp() {
 			m.selectedSuggestionIndex = len(m.suggestions) - 1
 
 			// Scroll the view to show the bottom part of the list.
-			m.popupScrollOffset = max(
-				0, len(m.suggestions)-m.config.PopupMaxHeight,
+			m.popupScrollOffset = m.popupPageStart(
+				m.selectedSuggestionIndex,
 			)
 		} else if m.selectedSuggestionIndex < m.popupScrollOffset {
 			// If the new selection is above the current visible
@@ -735,22 +2468,92 @@ func (m *PromptModel) navigateAutocompleteDown() {
 
 			// Scroll the view to the top.
 			m.popupScrollOffset = 0
-		} else if m.selectedSuggestionIndex >=
-			m.popupScrollOffset+m.config.PopupMaxHeight {
+		} else if m.selectedSuggestionIndex >= m.popupScrollOffset+
+			m.popupPageSize(m.popupScrollOffset) {
 			// If the new selection is below the current visible
 			// area, scroll down. Adjust the scroll offset so the
 			// selection is the last visible item.
-			m.popupScrollOffset = m.selectedSuggestionIndex -
-				m.config.PopupMaxHeight + 1
+			m.popupScrollOffset = m.popupPageStart(
+				m.selectedSuggestionIndex,
+			)
 		}
 	}
 }
 
+// navigateAutocompletePageUp moves the selection and the scroll offset up by
+// one page of visible items within the suggestion list, clamping at the
+// top instead of wrapping.
+func (m *PromptModel) navigateAutocompletePageUp() {
+	// Only navigate if the popup is shown and suggestions exist.
+	if !m.showPopup || len(m.suggestions) == 0 {
+		return
+	}
+
+	pageSize := m.popupPageSize(m.popupScrollOffset)
+
+	// Move the selection and the visible window up by one page, clamping
+	// both at the first suggestion.
+	m.selectedSuggestionIndex = max(0, m.selectedSuggestionIndex-pageSize)
+	m.popupScrollOffset = max(0, m.popupScrollOffset-pageSize)
+
+	// Make sure the selection is still within the visible window.
+	if m.selectedSuggestionIndex >= m.popupScrollOffset+
+		m.popupPageSize(m.popupScrollOffset) {
+
+		m.popupScrollOffset = m.popupPageStart(
+			m.selectedSuggestionIndex,
+		)
+	}
+}
+
+// navigateAutocompletePageDown moves the selection and the scroll offset down
+// by one page of visible items within the suggestion list, clamping at
+// the bottom instead of wrapping.
+func (m *PromptModel) navigateAutocompletePageDown() {
+	// Only navigate if the popup is shown and suggestions exist.
+	if !m.showPopup || len(m.suggestions) == 0 {
+		return
+	}
+
+	pageSize := m.popupPageSize(m.popupScrollOffset)
+	lastIndex := len(m.suggestions) - 1
+
+	// Move the selection and the visible window down by one page, clamping
+	// the selection at the last suggestion and the window so that it
+	// never extends past the end of the list.
+	m.selectedSuggestionIndex = min(
+		lastIndex, m.selectedSuggestionIndex+pageSize,
+	)
+	m.popupScrollOffset = min(
+		m.popupPageStart(lastIndex), m.popupScrollOffset+pageSize,
+	)
+
+	// Make sure the selection is still within the visible window.
+	switch {
+	case m.selectedSuggestionIndex < m.popupScrollOffset:
+		m.popupScrollOffset = m.selectedSuggestionIndex
+
+	case m.selectedSuggestionIndex >= m.popupScrollOffset+
+		m.popupPageSize(m.popupScrollOffset):
+
+		m.popupScrollOffset = m.popupPageStart(
+			m.selectedSuggestionIndex,
+		)
+	}
+}
+
 // applyAutocomplete replaces the current word fragment with the selected
-// suggestion's Word.
+// suggestion's Word. An out-of-range selection is clamped to the suggestion
+// list and an empty list is a no-op.
 func (m *PromptModel) applyAutocomplete() {
 	// Only apply if the popup is shown and suggestions exist.
 	if m.showPopup && len(m.suggestions) > 0 {
+		// Make sure the selection points into the list, it could be
+		// stale if the suggestions changed.
+		m.selectedSuggestionIndex = max(
+			0, min(m.selectedSuggestionIndex, len(m.suggestions)-1),
+		)
+
 		// Get the currently selected suggestion struct.
 		selectedSuggestion := m.suggestions[m.selectedSuggestionIndex]
 
@@ -761,27 +2564,11 @@ func (m *PromptModel) applyAutocomplete() {
 		line := m.lines[m.cursorRow]
 		col := m.cursorCol
 
-		// Use configured function
-		isWordCharFn := m.config.IsWordCharFn
-
 		// Find the starting position of the word fragment being
-		// completed. Scan backwards from the cursor position.
-		start := col
+		// completed using the configured word character function.
+		_, start := WordFragmentAt(line, col, m.config.IsWordCharFn)
 		runes := []rune(line)
 
-		for start > 0 {
-			// Stop scanning if a non-word character is found.
-			if isWordCharFn(runes[start-1]) {
-				start--
-			} else {
-				break
-			}
-		}
-		// Ensure start index is valid.
-		if start < 0 {
-			start = 0
-		}
-
 		// Reconstruct the line:
 		// Part before fragment + selected suggestion word + part after
 		// original cursor position. Part before the word fragment.
@@ -800,27 +2587,60 @@ func (m *PromptModel) applyAutocomplete() {
 		} // If col == len(runes), suffix remains "" (correct).
 
 		// Update the current line in the model.
+		m.markEdited()
 		m.lines[m.cursorRow] = prefix + selectedText + suffix
 
 		// Move the cursor to the end of the inserted suggestion word.
-		m.cursorCol = start + len(selectedText)
+		m.cursorCol = start + utf8.RuneCountInString(selectedText)
 
-		// Hide the popup and reset autocomplete state.
-		m.clearAutocomplete()
+		// Hide the popup and reset autocomplete state, or refresh the
+		// suggestions for the new context if configured.
+		if m.config.KeepPopupAfterApply {
+			m.lastSuggestedWord = ""
+			m.updateAutocomplete()
+		} else {
+			m.clearAutocomplete()
+		}
+
+		m.emitEvent(Event{Kind: EventComplete, Text: selectedText})
+	}
+}
+
+// emitEvent reports the given event to the configured EventFn, if any.
+func (m *PromptModel) emitEvent(ev Event) {
+	m.eventEmitted = true
+
+	// Never leak masked input to the observer.
+	if m.config.MaskInput {
+		ev.Text = ""
+	}
+
+	if m.config.EventFn != nil {
+		m.config.EventFn(ev)
+	}
+}
+
+// notifyEdit emits an edit event if the current key press edited the input
+// and no more specific event was emitted for it.
+func (m *PromptModel) notifyEdit() {
+	if m.config.EventFn == nil || m.eventEmitted || !m.edited {
+		return
 	}
+
+	m.emitEvent(Event{Kind: EventEdit, Text: strings.Join(m.lines, "\n")})
 }
 
 // navigateHistoryUp loads the previous command from history into the input
 // area.
 func (m *PromptModel) navigateHistoryUp() {
 	// Do nothing if history is empty.
-	if len(m.history) == 0 {
+	if m.history.Len() == 0 {
 		return
 	}
 
 	// If not currently Browse history, start from the most recent entry.
 	if m.historyIndex == -1 {
-		m.historyIndex = len(m.history) - 1
+		m.historyIndex = m.history.Len() - 1
 	} else if m.historyIndex > 0 {
 		// If already Browse, move to the previous (older) entry.
 		m.historyIndex--
@@ -841,7 +2661,7 @@ func (m *PromptModel) navigateHistoryDown() {
 	}
 
 	// Check if there are more recent entries to navigate to.
-	if m.historyIndex < len(m.history)-1 {
+	if m.historyIndex < m.history.Len()-1 {
 		// Move to the next (more recent) history entry.
 		m.historyIndex++
 
@@ -853,6 +2673,7 @@ func (m *PromptModel) navigateHistoryDown() {
 		m.historyIndex = -1
 
 		// Reset to a single empty line.
+		m.markEdited()
 		m.lines = []string{""}
 		m.cursorRow = 0
 		m.cursorCol = 0
@@ -866,75 +2687,237 @@ func (m *PromptModel) navigateHistoryDown() {
 // specified by the current m.historyIndex.
 func (m *PromptModel) loadHistoryEntry() {
 	// Check if the history index is valid.
-	if m.historyIndex >= 0 && m.historyIndex < len(m.history) {
+	if m.historyIndex >= 0 && m.historyIndex < m.history.Len() {
+		// Fetch the entry only once, the store may be expensive to
+		// query.
+		entry := m.history.At(m.historyIndex)
+
 		// Split the stored command (which might be multi-line) into
 		// lines.
-		m.lines = strings.Split(m.history[m.historyIndex], "\n")
-		// Position the cursor at the end of the loaded command.
-		m.cursorRow = len(m.lines) - 1
-		// Handle case where history entry might be empty or invalid.
-		if m.cursorRow < 0 {
-			m.cursorRow = 0
-		}
-		if len(m.lines) > 0 {
-			m.cursorCol = len(m.lines[m.cursorRow])
-		} else {
-			// If history entry resulted in no lines, reset to a
-			// safe state.
-			m.lines = []string{""}
+		m.markEdited()
+		m.lines = strings.Split(entry, "\n")
+		// If history entry resulted in no lines, reset to a safe
+		// state.
+		m.ensureNonEmpty()
+
+		// Position the cursor at the end of the loaded command, or at
+		// its start if configured. The column is a rune index, so it
+		// is always valid for the loaded content.
+		if m.config.HistoryCursorAtStart {
 			m.cursorRow = 0
 			m.cursorCol = 0
+		} else {
+			m.cursorRow = len(m.lines) - 1
+			m.cursorCol = m.lineLen(m.cursorRow)
 		}
 		// Clear any autocomplete suggestions shown before history
 		// navigation.
 		m.clearAutocomplete()
+
+		// Notify the user about the recalled entry, if configured.
+		if m.config.OnHistoryNavigate != nil {
+			m.config.OnHistoryNavigate(entry, m.historyIndex)
+		}
+		m.emitEvent(Event{
+			Kind:  EventHistoryNavigate,
+			Text:  entry,
+			Index: m.historyIndex,
+		})
 	}
 }
 
+// historyEnterSubmits returns whether Enter submits a recalled history entry,
+// which is the default if HistoryEnterSubmits isn't set.
+func (m *PromptModel) historyEnterSubmits() bool {
+	return m.config.HistoryEnterSubmits == nil ||
+		*m.config.HistoryEnterSubmits
+}
+
 // handleEnter determines whether to submit the command or insert a newline,
-// based on the configured IsCompleteFn.
-func (m *PromptModel) handleEnter() {
-	// Get the current input, potentially spanning multiple lines.
+// based on the configured IsCompleteFn. It returns the command of an
+// asynchronous execution, if any.
+func (m *PromptModel) handleEnter() tea.Cmd {
+	// Ignore Enter on a completely empty buffer if configured.
+	if m.config.IgnoreEmptyEnter && len(m.lines) == 1 && m.lines[0] == "" {
+		return nil
+	}
+
+	// Accept a recalled history entry for editing instead of submitting
+	// it if configured.
+	if !m.historyEnterSubmits() && m.historyIndex != -1 {
+		m.historyIndex = -1
+		m.clearAutocomplete()
+
+		return nil
+	}
+
+	// Get the current input, potentially spanning multiple lines, and
+	// submit it if it is complete.
 	fullInput := m.getCurrentInput()
-	// Use the configured function to check if the input is complete.
-	isComplete := m.config.IsCompleteFn(fullInput)
-
-	// Check if complete and avoid submitting just an empty semicolon.
-	if isComplete && strings.TrimSpace(fullInput) != ";" {
-		// Check if an execution function is configured.
-		if m.config.ExecuteFn != nil {
-			// Call the configured function and store its output.
-			output := m.config.ExecuteFn(fullInput)
-			// Format the output for display in the View.
-			m.lastOutput = fmt.Sprintf("\n--- Executing ---\n%s\n-----------------\n", output)
-		} else {
-			// Provide feedback if no execution function is set.
-			m.lastOutput = "\n--- No ExecuteFn Configured ---\n"
-		}
+	if m.isComplete(fullInput) {
+		return m.submitInput(fullInput, false)
+	}
 
-		// Add the submitted command to history if it's not just
-		// whitespace.
-		if strings.TrimSpace(fullInput) != "" {
-			m.history = append(m.history, fullInput)
-		}
+	// Input is not complete, so insert a newline.
+	m.insertNewline()
 
-		// Reset the input state for the next command.
-		m.lines = []string{""}
-		m.cursorRow = 0
-		m.cursorCol = 0
+	// Clear suggestions when inserting a newline.
+	m.clearAutocomplete()
 
-		// Exit history Browse mode.
-		m.historyIndex = -1
+	return nil
+}
 
-		// Clear suggestions.
-		m.clearAutocomplete()
+// isComplete reports whether the given input is ready to be submitted, using
+// the configured IsCompleteFn. Commands configured for immediate execution are
+// always complete, whereas blank input never is, even for checks such as
+// DefaultIsCompleteBalanced that accept it.
+func (m *PromptModel) isComplete(input string) bool {
+	trimmed := strings.TrimSpace(input)
+	if trimmed == "" {
+		return false
+	}
 
-	} else {
-		// Input is not complete, so insert a newline.
-		m.insertNewline()
+	return m.config.IsCompleteFn(input) ||
+		m.config.ImmediateCommands[trimmed]
+}
 
-		// Clear suggestions when inserting a newline.
-		m.clearAutocomplete()
+// Submit executes the current input as if it had been submitted with Enter.
+// The input is only checked for completeness if SubmitChecksComplete is set.
+// The input is executed, stored in the history and the input area is reset.
+// It returns the command of an asynchronous execution, if any. Empty or
+// incomplete input is not submitted.
+func (m *PromptModel) Submit() tea.Cmd {
+	fullInput := m.getCurrentInput()
+	if strings.TrimSpace(fullInput) == "" {
+		return nil
+	}
+
+	if m.config.SubmitChecksComplete && !m.isComplete(fullInput) {
+		return nil
+	}
+
+	return m.submitInput(fullInput, false)
+}
+
+// SubmitKeep executes the current input and stores it in the history like
+// Submit, but leaves the input intact for further editing, with the cursor at
+// its end. It returns the command of an asynchronous execution, if any. Empty
+// input is not submitted.
+func (m *PromptModel) SubmitKeep() tea.Cmd {
+	fullInput := m.getCurrentInput()
+	if strings.TrimSpace(fullInput) == "" {
+		return nil
+	}
+
+	return m.submitInput(fullInput, true)
+}
+
+// submitInput executes the given input, adds it to the history and resets the
+// input state for the next command. If keepInput is set, the input is left
+// intact instead and the cursor moves to its end. It returns the command of an
+// asynchronous execution, if any.
+func (m *PromptModel) submitInput(fullInput string, keepInput bool) tea.Cmd {
+	// Remove interior blank lines if configured.
+	if m.config.CollapseBlankLines {
+		fullInput = collapseBlankLines(fullInput)
+	}
+
+	// Transform the input (e.g., expand aliases) if configured.
+	execInput := m.transformInput(fullInput)
+
+	// Execute the input with the configured function.
+	m.emitEvent(Event{Kind: EventSubmit, Text: fullInput})
+	cmd := m.execute(execInput)
+
+	// Store either the input as typed or as executed in the history.
+	historyEntry := fullInput
+	if m.config.HistoryStoresTransformed {
+		historyEntry = execInput
+	}
+
+	// Add the submitted command to history if it's not just whitespace.
+	// Masked input is never stored.
+	if strings.TrimSpace(historyEntry) != "" && !m.config.MaskInput {
+		m.history.Append(historyEntry)
+	}
+
+	// Exit history Browse mode.
+	m.historyIndex = -1
+
+	// Clear suggestions and end any selection.
+	m.clearAutocomplete()
+	m.resetSelection()
+
+	// Keep the input for further editing if requested.
+	if keepInput {
+		m.moveCursorToEnd()
+		return cmd
+	}
+
+	// Reset the input state for the next command. The submitted input
+	// can't be undone anymore.
+	m.lines = []string{""}
+	m.cursorRow = 0
+	m.cursorCol = 0
+	m.resetUndo()
+
+	// Start typing the next command in Vi insert mode.
+	m.viState = viInsert
+
+	// Offer the seeded suggestions again for the next command.
+	m.showSuggestionsOnEmpty()
+
+	return cmd
+}
+
+// transformInput returns the given input as it is to be executed, transformed
+// by the configured PreExecuteFn, if any.
+func (m *PromptModel) transformInput(input string) string {
+	if m.config.PreExecuteFn == nil {
+		return input
+	}
+
+	return m.config.PreExecuteFn(input)
+}
+
+// execute runs the given input with the configured execution function.
+// ExecuteAsyncFn takes precedence over ExecuteFn. The output of a synchronous
+// execution is stored for display, while the command of an asynchronous
+// execution is returned.
+func (m *PromptModel) execute(input string) tea.Cmd {
+	// A new execution supersedes any output still being streamed, so that
+	// edits clear the output again.
+	m.streaming = false
+
+	switch {
+	// Dispatch asynchronous execution. The result is expected to be
+	// delivered later via OutputMsg or OutputChunkMsg.
+	case m.config.ExecuteAsyncFn != nil:
+		m.setOutput("")
+
+		// Remember when the execution started to report its duration
+		// once the output arrives, and show the busy prompt until then.
+		m.execStart = time.Now()
+		m.busy = true
+
+		return m.config.ExecuteAsyncFn(input)
+
+	// Call the configured function and store its output formatted for
+	// display in the View, including the execution time if configured.
+	case m.config.ExecuteFn != nil:
+		start := time.Now()
+		output := m.emptyOutputPlaceholder(m.config.ExecuteFn(input))
+		if m.config.ShowTiming {
+			output += "\n" + formatTiming(time.Since(start))
+		}
+
+		m.setOutput(formatOutput(output))
+		return nil
+
+	// Provide feedback if no execution function is set.
+	default:
+		m.setOutput("\n--- No ExecuteFn Configured ---\n")
+		return nil
 	}
 }
 
@@ -944,17 +2927,31 @@ func (m *PromptModel) handleBackspace() {
 	// Note: updateAutocomplete is called after this in handleKeyPress
 }
 
-// handleAutocompleteTab calls the logic to apply the selected suggestion.
+// handleAutocompleteTab calls the logic to apply the selected suggestion, or
+// moves the selection down if TabCycles is set.
 func (m *PromptModel) handleAutocompleteTab() {
+	// Cycle through the suggestions instead if configured.
+	if m.config.TabCycles {
+		if m.showPopup {
+			m.navigateAutocompleteDown()
+		}
+
+		return
+	}
+
 	m.applyAutocomplete()
 }
 
 // handleUpArrow dispatches to the appropriate action based on context:
 // navigate suggestions, navigate history, or move cursor up.
 func (m *PromptModel) handleUpArrow() {
-	if m.showPopup {
+	if m.showPopup && !m.popupSeeded {
 		// If popup is visible, navigate suggestions.
 		m.navigateAutocompleteUp()
+	} else if m.config.SoftWrap && m.moveCursorVisualUp() {
+		// With soft wrapping, the cursor first moves up through the
+		// visual rows of the current line.
+		return
 	} else if m.cursorRow == 0 {
 		// If at the top line and no popup, navigate history.
 		m.navigateHistoryUp()
@@ -967,9 +2964,13 @@ func (m *PromptModel) handleUpArrow() {
 // handleDownArrow dispatches to the appropriate action based on context:
 // navigate suggestions, navigate history, or move cursor down.
 func (m *PromptModel) handleDownArrow() {
-	if m.showPopup {
+	if m.showPopup && !m.popupSeeded {
 		// If popup is visible, navigate suggestions.
 		m.navigateAutocompleteDown()
+	} else if m.config.SoftWrap && m.moveCursorVisualDown() {
+		// With soft wrapping, the cursor first moves down through the
+		// visual rows of the current line.
+		return
 	} else if m.historyIndex != -1 {
 		// If currently Browse history, navigate history down.
 		m.navigateHistoryDown()
@@ -989,58 +2990,42 @@ func (m PromptModel) View() string {
 
 	// 1. Display output from the last executed command, if any.
 	if m.lastOutput != "" {
-		// Trim trailing newlines from the stored output to prevent
-		// double spacing.
-		sb.WriteString(strings.TrimRight(m.lastOutput, "\n"))
+		// Format the output, applying truncation and prompt prefixes
+		// as configured.
+		sb.WriteString(m.renderOutput())
 
 		// Add exactly one newline after the output block.
 		sb.WriteRune('\n')
+
+		// Separate the output from the prompt by a blank line if
+		// configured.
+		if m.config.TrailingNewlineAfterOutput {
+			sb.WriteRune('\n')
+		}
 	}
 
-	// 2. Render the input lines.
+	// 2. Render the input lines, preceded by all but the last line of a
+	// multi-line primary prompt.
+	for _, header := range m.promptHeader() {
+		sb.WriteString(styles.Prompt.Render(header))
+		sb.WriteRune('\n')
+	}
 	for i, line := range m.lines {
-		// Determine the correct prompt string based on the line number.
-		prefix := m.config.PromptPrimary
-		if i > 0 {
-			prefix = m.config.PromptSecondary
-		}
-		// Render the prompt string with its configured style.
-		sb.WriteString(styles.Prompt.Render(prefix))
-
-		// Check if this is the line the cursor is currently on.
-		if i == m.cursorRow {
-			// Render the line character by character to insert the
-			// cursor. Use runes for correct indexing.
-			runes := []rune(line)
-			for j := 0; j <= len(runes); j++ {
-				// Check if this is the cursor's column
-				// position.
-				if j == m.cursorCol {
-					// Determine the character under the
-					// cursor (or space if at end).
-					cursorChar := " "
-					if j < len(runes) {
-						cursorChar = string(runes[j])
-					}
-
-					// Render the character/space with the
-					// cursor style.
-					sb.WriteString(
-						styles.Cursor.Render(cursorChar),
-					)
-				}
-
-				// Write the original character if it's not the
-				// one under the cursor. Ensure index j is
-				// within the bounds of the runes slice.
-				if j < len(runes) && j != m.cursorCol {
-					sb.WriteRune(runes[j])
-				}
-			}
+		// Render the line number gutter and the prompt string for
+		// this row with their configured styles.
+		sb.WriteString(styles.LineNumber.Render(m.gutterFor(i)))
+		sb.WriteString(styles.Prompt.Render(m.promptFor(i)))
+
+		// Right-align lines with right-to-left content if configured.
+		sb.WriteString(m.rtlPadding(i))
+
+		// Render the line content, including the cursor if it is on
+		// this line. A disabled prompt is rendered dimmed, without a
+		// cursor.
+		if m.disabled {
+			sb.WriteString(styles.Disabled.Render(m.displayLine(i)))
 		} else {
-			// If it's not the cursor line, render the line content
-			// directly.
-			sb.WriteString(line)
+			sb.WriteString(m.renderInputLine(i))
 		}
 
 		// Add a newline after rendering the line content, unless it's
@@ -1051,140 +3036,1332 @@ func (m PromptModel) View() string {
 		}
 	}
 
-	// 3. Render the autocomplete popup if it should be visible.
-	if m.showPopup && len(m.suggestions) > 0 {
+	// 3. Render the autocomplete popup if it should be visible. Inline
+	// suggestions are rendered as ghost text instead.
+	if m.popupRendered() {
 		// Add spacing before the popup if the last line written wasn't
 		// a newline.
 		if sb.Len() > 0 && sb.String()[sb.Len()-1] != '\n' {
 			sb.WriteRune('\n')
 		}
 
-		// To hold the rendered suggestion strings.
-		suggestionLines := []string{}
+		sb.WriteString(m.renderPopup())
+	}
 
-		// Determine the range of suggestions to display based on
-		// scrolling.
-		maxH := m.config.PopupMaxHeight
-		numSuggestions := len(m.suggestions)
+	return sb.String()
+}
 
-		// Ensure scroll offset is valid (can become invalid if
-		// suggestions change).
-		if m.popupScrollOffset >= numSuggestions {
-			m.popupScrollOffset = max(0, numSuggestions-1)
-		}
+// promptFor returns the prompt string for the given row: the primary prompt
+// (or the busy prompt while an asynchronous execution is pending) for the
+// first line and the secondary prompt for subsequent lines. Prompts
+// containing newlines are multi-line prompts, of which only the last line is
+// rendered in front of the input. For the secondary prompt, the preceding
+// lines are dropped.
+func (m PromptModel) promptFor(row int) string {
+	if row > 0 {
+		return m.secondaryPrompt()
+	}
 
-		// First visible index.
-		startIdx := m.popupScrollOffset
+	_, last := splitPrompt(m.primaryPrompt())
 
-		// Last visible index (exclusive).
-		endIdx := min(startIdx+maxH, numSuggestions)
+	return last
+}
 
-		// Calculate the maximum display width of the suggestion words
-		// in the visible range to allow for aligning the descriptions.
-		maxWordWidth := 0
-		for i := startIdx; i < endIdx; i++ {
-			// Use runewidth.StringWidth for accurate width of
-			// potentially wide characters.
-			width := runewidth.StringWidth(m.suggestions[i].Text)
+// primaryPrompt returns the full, possibly multi-line, prompt for the first
+// line: the busy prompt while an asynchronous execution is pending (if
+// configured) or the primary prompt.
+func (m PromptModel) primaryPrompt() string {
+	if m.busy && m.config.BusyPrompt != "" {
+		return m.config.BusyPrompt
+	}
 
-			if width > maxWordWidth {
-				maxWordWidth = width
-			}
-		}
+	return m.config.PromptPrimary
+}
 
-		// Iterate through the *visible* suggestions only.
-		for i := startIdx; i < endIdx; i++ {
-			// Get the current suggestion struct.
-			sugg := m.suggestions[i]
-			textPart := sugg.Text
-			descPart := ""
+// promptHeader returns all but the last line of a multi-line primary prompt,
+// which are rendered on their own lines above the input.
+func (m PromptModel) promptHeader() []string {
+	header, _ := splitPrompt(m.primaryPrompt())
 
-			// Format the description part if enabled and available.
-			if m.config.ShowDescription && sugg.Description != "" {
-				// Apply the configured description style.
-				descPart = styles.Description.Render(
-					sugg.Description,
-				)
-			}
+	return header
+}
 
-			// Pad the word part with spaces to align the
-			// descriptions. Calculate padding needed based on rune
-			// width.
-			padding := maxWordWidth - runewidth.StringWidth(
-				textPart,
-			)
+// splitPrompt splits a possibly multi-line prompt into the lines before the
+// last one and the last line, which is rendered in front of the input and
+// determines the column the input starts in.
+func splitPrompt(prompt string) ([]string, string) {
+	lines := strings.Split(prompt, "\n")
 
-			// Avoid negative padding.
-			if padding < 0 {
-				padding = 0
-			}
-			paddedWord := textPart + strings.Repeat(" ", padding)
+	return lines[:len(lines)-1], lines[len(lines)-1]
+}
 
-			// Combine the padded word and the description using
-			// lipgloss.JoinHorizontal. This helps manage spacing
-			// and potential future styling. Add separator spaces.
-			line := lipgloss.JoinHorizontal(
-				lipgloss.Left, paddedWord, "  ", descPart,
-			)
+// renderWidth returns the width available for rendering the prompt: the
+// configured MaxWidth if it is set and narrower than the terminal, the
+// terminal width otherwise. Zero means the width is unknown.
+func (m PromptModel) renderWidth() int {
+	if m.config.MaxWidth > 0 &&
+		(m.width <= 0 || m.config.MaxWidth < m.width) {
 
-			// Determine the style for the current line (selected or
-			// unselected).
-			style := styles.UnselectedItem
-			if i == m.selectedSuggestionIndex {
-				style = styles.SelectedItem
-			}
+		return m.config.MaxWidth
+	}
 
-			// Render the complete line with the appropriate style.
-			suggestionLines = append(
-				suggestionLines, style.Render(line),
-			)
-		}
+	return m.width
+}
 
-		// Join the rendered lines and apply the overall popup box style.
-		sb.WriteString(styles.PopupBox.Render(
-			strings.Join(suggestionLines, "\n")),
-		)
+// rtlPadding returns the spaces that right-align the input line at the given
+// row within the render width if RightAlignRTL is set and the line has
+// predominantly right-to-left content. Otherwise, an empty string is returned.
+func (m PromptModel) rtlPadding(row int) string {
+	width := m.renderWidth()
+	if !m.config.RightAlignRTL || m.config.MaskInput || width <= 0 ||
+		!isRTL(m.lines[row]) {
+
+		return ""
 	}
 
-	return sb.String()
+	// Reserve one cell for the cursor at the end of the line.
+	padding := width - m.prefixWidth(row) -
+		runewidth.StringWidth(m.lines[row]) - 1
+	if padding <= 0 {
+		return ""
+	}
+
+	return strings.Repeat(" ", padding)
 }
 
-// joinNonEmptyLines combines lines from a slice, removing any trailing lines
-// that consist only of whitespace. Used before executing a command.
-func joinNonEmptyLines(lines []string) string {
-	end := len(lines)
+// isRTL reports whether the given text has predominantly right-to-left
+// content, i.e., it contains more letters of right-to-left scripts than
+// letters of other scripts.
+func isRTL(s string) bool {
+	rtl, ltr := 0, 0
+	for _, r := range s {
+		switch {
+		case unicode.In(r, unicode.Arabic, unicode.Hebrew,
+			unicode.Syriac, unicode.Thaana, unicode.Nko):
 
-	// Iterate backwards through the lines.
-	for end > 0 {
-		// Stop at the first line that contains non-whitespace
-		// characters.
-		if strings.TrimSpace(lines[end-1]) != "" {
-			break
+			rtl++
+
+		case unicode.IsLetter(r):
+			ltr++
 		}
+	}
 
-		// Decrement end pointer if the line is empty/whitespace.
-		end--
+	return rtl > ltr
+}
+
+// showLineNumbers returns whether the line number gutter should be rendered
+// for the current buffer.
+func (m PromptModel) showLineNumbers() bool {
+	if !m.config.ShowLineNumbers {
+		return false
 	}
 
-	// Join the lines up to the last non-empty one found.
-	return strings.Join(lines[:end], "\n")
+	return !m.config.LineNumbersMultilineOnly || len(m.lines) > 1
 }
 
-// getCurrentInput is a convenience method on the model to get the processed
-// input string.
-func (m *PromptModel) getCurrentInput() string {
-	return joinNonEmptyLines(m.lines)
+// gutterFor returns the line number gutter for the given row, right aligned
+// to the width of the highest line number, or an empty string if line numbers
+// aren't shown.
+func (m PromptModel) gutterFor(row int) string {
+	if !m.showLineNumbers() {
+		return ""
+	}
+
+	digits := len(strconv.Itoa(len(m.lines)))
+
+	return fmt.Sprintf("%*d ", digits, row+1)
+}
+
+// prefixWidth returns the display width of everything rendered in front of
+// the input line at the given row: the line number gutter and the prompt.
+func (m PromptModel) prefixWidth(row int) int {
+	return runewidth.StringWidth(m.gutterFor(row)) +
+		runewidth.StringWidth(m.promptFor(row))
+}
+
+// ghostText returns the part of the selected suggestion that isn't typed yet,
+// rendered as ghost text after the cursor with InlineSuggestions. It returns an
+// empty string if inline suggestions are disabled, the input is masked, no
+// suggestion is available or the selected one doesn't start with the typed
+// word fragment.
+func (m PromptModel) ghostText() string {
+	if !m.config.InlineSuggestions || m.config.MaskInput || !m.showPopup ||
+		m.selectedSuggestionIndex >= len(m.suggestions) {
+
+		return ""
+	}
+
+	text := []rune(m.suggestions[m.selectedSuggestionIndex].Text)
+	fragment := []rune(m.lastSuggestedWord)
+	if len(fragment) > len(text) || !strings.EqualFold(
+		string(text[:len(fragment)]), string(fragment),
+	) {
+
+		return ""
+	}
+
+	return string(text[len(fragment):])
+}
+
+// secondaryPrompt returns the prompt string for continuation lines. With
+// AlignSecondaryPrompt, it is padded on the left to the width of the last line
+// of the current primary prompt (or the busy prompt), so that the input of all
+// lines starts in the same column.
+func (m PromptModel) secondaryPrompt() string {
+	_, prompt := splitPrompt(m.config.PromptSecondary)
+	if !m.config.AlignSecondaryPrompt {
+		return prompt
+	}
+
+	_, primary := splitPrompt(m.primaryPrompt())
+	padding := runewidth.StringWidth(primary) -
+		runewidth.StringWidth(prompt)
+	if padding <= 0 {
+		return prompt
+	}
+
+	return strings.Repeat(" ", padding) + prompt
+}
+
+// PromptWidth returns the display width of the prompt rendered in front of the
+// input line at the given row: the primary prompt for the first row and the
+// (possibly aligned) secondary prompt otherwise. The line number gutter isn't
+// included.
+func (m *PromptModel) PromptWidth(row int) int {
+	return runewidth.StringWidth(m.promptFor(row))
+}
+
+// renderInputLine renders the content of the input line at the given row. If
+// the cursor is on this line, the character under it is rendered with the
+// cursor style. With soft wrapping, the line is broken into visual rows that
+// are indented to align with the text after the prompt.
+func (m PromptModel) renderInputLine(row int) string {
+	var sb strings.Builder
+
+	styles := m.config.Styles
+
+	// Determine where the visual rows of a soft wrapped line start and
+	// the indentation of the continuation rows.
+	rowStarts := m.visualRowStarts(row)
+
+	// Lines without a cursor or selection that fit on a single visual
+	// row are rendered as a whole instead of character by character,
+	// which keeps rendering large buffers fast.
+	if len(rowStarts) == 1 && !m.blockMode && !m.hasCursorOnRow(row) {
+		return m.displayLine(row)
+	}
+
+	indent := strings.Repeat(" ", m.prefixWidth(row))
+	nextRow := 1
+
+	// Render the line character by character to insert the cursor and
+	// line breaks. Use runes for correct indexing.
+	runes := m.displayRunes(row)
+	for j := 0; j <= len(runes); j++ {
+		// Continue on the next visual row if the line wraps here.
+		if nextRow < len(rowStarts) && j == rowStarts[nextRow] {
+			sb.WriteRune('\n')
+			sb.WriteString(indent)
+			nextRow++
+		}
+
+		// Check if this is the position of a cursor.
+		if m.isCursorAt(row, j) {
+			// Determine the character under the cursor (or the
+			// end-of-line cursor glyph, a space by default, if at
+			// end).
+			cursorChar := " "
+			if j < len(runes) {
+				cursorChar = string(runes[j])
+			} else if m.config.EOLCursorChar != "" {
+				cursorChar = m.config.EOLCursorChar
+			}
+
+			// Show the remainder of the selected suggestion as
+			// ghost text after the primary cursor at the end of
+			// the line, with the cursor on its first character.
+			var ghost []rune
+			if j == len(runes) && row == m.cursorRow {
+				ghost = []rune(m.ghostText())
+			}
+			if len(ghost) > 0 {
+				cursorChar = string(ghost[0])
+			}
+
+			// Render the character/space with the cursor style,
+			// which differs while the prompt is blurred.
+			cursorStyle := styles.Cursor
+			if m.blurred {
+				cursorStyle = styles.BlurredCursor
+			}
+			sb.WriteString(cursorStyle.Render(cursorChar))
+
+			if len(ghost) > 1 {
+				sb.WriteString(
+					styles.Ghost.Render(string(ghost[1:])),
+				)
+			}
+
+			continue
+		}
+
+		// Write the original character. Ensure index j is within the
+		// bounds of the runes slice. Characters within a block
+		// selection are highlighted.
+		switch {
+		case j < len(runes) && m.inBlock(row, j):
+			sb.WriteString(
+				styles.Selection.Render(string(runes[j])),
+			)
+
+		case j < len(runes):
+			sb.WriteRune(runes[j])
+		}
+	}
+
+	return sb.String()
+}
+
+// visualRowStarts returns the rune indices at which the visual rows of the
+// input line at the given row start. With SoftWrap enabled and a known
+// render width, lines wider than the space after the prompt are broken
+// into multiple visual rows. Otherwise, a single row starting at index zero is
+// returned.
+func (m PromptModel) visualRowStarts(row int) []int {
+	starts := []int{0}
+
+	// The text width is the render width minus the width of the gutter
+	// and the prompt.
+	width := m.renderWidth()
+	textWidth := width - m.prefixWidth(row)
+	if !m.config.SoftWrap || width <= 0 || textWidth <= 0 {
+		return starts
+	}
+
+	// Start a new visual row whenever the next rune would exceed the
+	// text width.
+	rowWidth := 0
+	for j, r := range m.displayRunes(row) {
+		runeWidth := runewidth.RuneWidth(r)
+		if rowWidth > 0 && rowWidth+runeWidth > textWidth {
+			starts = append(starts, j)
+			rowWidth = 0
+		}
+		rowWidth += runeWidth
+	}
+
+	return starts
+}
+
+// displayRunes returns the runes of the input line at the given row as they
+// are displayed. With MaskInput, every rune is replaced by the mask rune.
+func (m PromptModel) displayRunes(row int) []rune {
+	runes := []rune(m.lines[row])
+	if !m.config.MaskInput {
+		return runes
+	}
+
+	for j := range runes {
+		runes[j] = m.config.MaskRune
+	}
+
+	return runes
+}
+
+// displayLine returns the input line at the given row as it is displayed.
+// With MaskInput, every rune is replaced by the mask rune.
+func (m PromptModel) displayLine(row int) string {
+	if !m.config.MaskInput {
+		return m.lines[row]
+	}
+
+	return string(m.displayRunes(row))
+}
+
+// cursorVisualRow returns the visual row starts of the cursor line and the
+// index of the visual row the cursor is on.
+func (m PromptModel) cursorVisualRow() ([]int, int) {
+	rowStarts := m.visualRowStarts(m.cursorRow)
+
+	visualRow := 0
+	for k, start := range rowStarts {
+		if m.cursorCol >= start {
+			visualRow = k
+		}
+	}
+
+	return rowStarts, visualRow
+}
+
+// moveCursorVisualUp moves the cursor to the previous visual row of a soft
+// wrapped line, keeping its offset within the row if possible. It reports
+// whether the cursor was moved, i.e., whether it wasn't on the first visual
+// row.
+func (m *PromptModel) moveCursorVisualUp() bool {
+	rowStarts, visualRow := m.cursorVisualRow()
+	if visualRow == 0 {
+		return false
+	}
+
+	// Keep the offset within the row, but stay on the previous row.
+	offset := m.cursorCol - rowStarts[visualRow]
+	m.cursorCol = min(
+		rowStarts[visualRow-1]+offset, rowStarts[visualRow]-1,
+	)
+
+	return true
+}
+
+// moveCursorVisualDown moves the cursor to the next visual row of a soft
+// wrapped line, keeping its offset within the row if possible. It reports
+// whether the cursor was moved, i.e., whether it wasn't on the last visual
+// row.
+func (m *PromptModel) moveCursorVisualDown() bool {
+	rowStarts, visualRow := m.cursorVisualRow()
+	if visualRow == len(rowStarts)-1 {
+		return false
+	}
+
+	// Determine the last cursor position on the next row. On the last
+	// visual row, the cursor may also be placed after the last rune.
+	rowEnd := m.lineLen(m.cursorRow)
+	if visualRow+2 < len(rowStarts) {
+		rowEnd = rowStarts[visualRow+2] - 1
+	}
+
+	// Keep the offset within the row, but stay on the next row.
+	offset := m.cursorCol - rowStarts[visualRow]
+	m.cursorCol = min(rowStarts[visualRow+1]+offset, rowEnd)
+
+	return true
+}
+
+// renderPopup renders the visible part of the suggestion list, applying the
+// configured styles and the overall popup box style.
+func (m PromptModel) renderPopup() string {
+	// Get the configured styles.
+	styles := m.config.Styles
+
+	// To hold the rendered suggestion strings.
+	suggestionLines := []string{}
+
+	// Determine the range of suggestions to display based on scrolling.
+	numSuggestions := len(m.suggestions)
+
+	// Ensure scroll offset is valid (can become invalid if suggestions
+	// change).
+	scrollOffset := m.popupScrollOffset
+	if scrollOffset >= numSuggestions {
+		scrollOffset = max(0, numSuggestions-1)
+	}
+
+	// First visible index.
+	startIdx := scrollOffset
+
+	// Last visible index (exclusive).
+	endIdx := min(startIdx+m.popupPageSize(startIdx), numSuggestions)
+
+	// Calculate the maximum display width of the suggestion words and
+	// descriptions to allow for aligning the descriptions. Normally only
+	// the visible suggestions are measured, but a stable popup width
+	// requires the widths of all of them, which are measured once when
+	// the suggestions are set.
+	maxWordWidth, maxDescWidth := m.stableWordWidth, m.stableDescWidth
+	if !m.config.StablePopupWidth {
+		maxWordWidth, maxDescWidth = m.measureSuggestions(
+			m.suggestions[startIdx:endIdx],
+		)
+	}
+
+	// Descriptions are either rendered inline after the suggestion
+	// words, aligned in a column, or indented on the lines below.
+	descBelow := m.config.DescriptionBelow
+	descIndent := strings.Repeat(" ", maxWordWidth)
+	if descBelow {
+		descIndent = ""
+	}
+
+	// With a stable popup width, every line is padded to the width of the
+	// widest possible line.
+	lineWidth := 0
+	if m.config.StablePopupWidth {
+		lineWidth = maxWordWidth + 2 + maxDescWidth
+		if descBelow {
+			lineWidth = max(maxWordWidth, maxDescWidth) + 2
+		}
+	}
+
+	// Unselected suggestions are padded to the width of the selection
+	// marker, if any.
+	selectedPrefix := m.config.SelectedPrefix
+	unselectedPrefix := strings.Repeat(
+		" ", runewidth.StringWidth(selectedPrefix),
+	)
+	if lineWidth > 0 {
+		lineWidth += len(unselectedPrefix)
+	}
+
+	// Track the lines holding group separators, which are filled in once
+	// the popup width is known.
+	var separatorLines []int
+
+	// Iterate through the *visible* suggestions only.
+	for i := startIdx; i < endIdx; i++ {
+		// Get the current suggestion struct.
+		sugg := m.suggestions[i]
+
+		// Separate the suggestion from the previous visible one if it
+		// starts a new group.
+		if i > startIdx && sugg.Group != m.suggestions[i-1].Group {
+			separatorLines = append(
+				separatorLines, len(suggestionLines),
+			)
+			suggestionLines = append(suggestionLines, "")
+		}
+		textPart := m.suggestionText(sugg)
+
+		// Determine the style and marker for the current line
+		// (selected or unselected).
+		style := styles.UnselectedItem
+		prefix := unselectedPrefix
+		if i == m.selectedSuggestionIndex {
+			style = styles.SelectedItem
+			prefix = selectedPrefix
+		}
+
+		// Every piece of the line is rendered on its own with the
+		// colors and emphasis of the line's style, since the reset at
+		// the end of a nested style would otherwise clear the line's
+		// style for the rest of the line.
+		rowStyle := textStyle(style)
+		descStyle := styles.Description.Inherit(rowStyle)
+		plain := func(s string) string {
+			if s == "" {
+				return ""
+			}
+
+			return rowStyle.Render(s)
+		}
+
+		// Format the description part if enabled and available.
+		descLines := m.descriptionLines(sugg)
+
+		// Apply the configured description style to the first line of
+		// an inline description.
+		descPart := ""
+		if len(descLines) > 0 && !descBelow {
+			descPart = descStyle.Render(descLines[0])
+			descLines = descLines[1:]
+		}
+
+		// Pad the word part with spaces to align the descriptions.
+		// Calculate padding needed based on rune width.
+		padding := maxWordWidth - runewidth.StringWidth(textPart)
+
+		// Avoid negative padding.
+		if padding < 0 {
+			padding = 0
+		}
+
+		// Emphasize the part of the suggestion matching the current
+		// word fragment.
+		textPart = highlightMatch(
+			textPart, m.lastSuggestedWord, rowStyle,
+			styles.MatchHighlight.Inherit(rowStyle),
+		)
+
+		// Combine the marker, the padded word and the description,
+		// separated by two spaces.
+		line := plain(prefix) + textPart +
+			plain(strings.Repeat(" ", padding)+"  ") + descPart
+
+		// Render the complete line with the appropriate style.
+		suggestionLines = append(
+			suggestionLines,
+			style.Render(padStyled(line, lineWidth, rowStyle)),
+		)
+
+		// Render any remaining description lines below the
+		// suggestion, either aligned with the description column or
+		// indented below the word.
+		for _, descLine := range descLines {
+			line := plain(unselectedPrefix+descIndent+"  ") +
+				descStyle.Render(descLine)
+			line = padStyled(line, lineWidth, rowStyle)
+			suggestionLines = append(
+				suggestionLines, style.Render(line),
+			)
+		}
+	}
+
+	// Fill in the group separators, spanning the full popup width.
+	if len(separatorLines) > 0 {
+		separatorWidth := 0
+		for _, line := range suggestionLines {
+			separatorWidth = max(
+				separatorWidth, lipgloss.Width(line),
+			)
+		}
+
+		separator := styles.GroupSeparator.Render(
+			strings.Repeat("─", separatorWidth),
+		)
+		for _, idx := range separatorLines {
+			suggestionLines[idx] = separator
+		}
+	}
+
+	// Add a scrollbar column if configured and not all suggestions are
+	// visible.
+	visible := endIdx - startIdx
+	if m.config.ShowPopupScrollbar && numSuggestions > visible {
+		suggestionLines = addScrollbar(
+			suggestionLines, scrollOffset, visible, numSuggestions,
+		)
+	}
+
+	// Show the position of the selection within all suggestions on top
+	// if configured.
+	if m.config.ShowSuggestionCount {
+		count := fmt.Sprintf(
+			"%d/%d", m.selectedSuggestionIndex+1, numSuggestions,
+		)
+		suggestionLines = append(
+			[]string{styles.SuggestionCount.Render(count)},
+			suggestionLines...,
+		)
+	}
+
+	// Join the rendered lines and apply the overall popup box style.
+	return styles.PopupBox.Render(strings.Join(suggestionLines, "\n"))
+}
+
+// popupRendered reports whether the View renders the suggestion popup box.
+func (m PromptModel) popupRendered() bool {
+	return m.showPopup && len(m.suggestions) > 0 && !m.disabled &&
+		!m.config.InlineSuggestions
+}
+
+// PopupRowCount returns the number of terminal rows the suggestion popup
+// occupies in the View, taking PopupMaxHeight, descriptions, group separators
+// and the popup box style into account. It returns zero if no popup is
+// rendered. This allows layouts to reserve space for the popup.
+func (m *PromptModel) PopupRowCount() int {
+	if !m.popupRendered() {
+		return 0
+	}
+
+	// Count the rows of the visible suggestions, just like renderPopup
+	// lays them out, without rendering them.
+	start := min(m.popupScrollOffset, len(m.suggestions)-1)
+	end := min(start+m.popupPageSize(start), len(m.suggestions))
+
+	rows := 0
+	for i := start; i < end; i++ {
+		rows += m.suggestionRows(i, start)
+	}
+
+	// Add the suggestion count header and the frame of the popup box.
+	if m.config.ShowSuggestionCount {
+		rows++
+	}
+
+	return rows + m.config.Styles.PopupBox.GetVerticalFrameSize()
+}
+
+// popupPageSize returns the number of suggestions visible in the popup when
+// the suggestion at index start is the first visible one. The suggestions are
+// measured by the rows they are rendered on, so that the popup never exceeds
+// PopupMaxHeight rows. At least one suggestion is always visible.
+func (m PromptModel) popupPageSize(start int) int {
+	rows, count := 0, 0
+	for i := start; i < len(m.suggestions); i++ {
+		rows += m.suggestionRows(i, start)
+		if rows > m.config.PopupMaxHeight {
+			break
+		}
+
+		count++
+	}
+
+	return max(1, count)
+}
+
+// popupPageStart returns the index of the first visible suggestion of the
+// page that ends with the suggestion at index end, i.e., the scroll offset
+// that shows as many suggestions before end as fit within PopupMaxHeight.
+func (m PromptModel) popupPageStart(end int) int {
+	if end <= 0 {
+		return 0
+	}
+
+	start := end
+	rows := m.suggestionRows(end, end)
+	for start > 0 {
+		// Moving the start up adds the previous suggestion and the
+		// separator between it and the former start, if any.
+		rows += m.suggestionRows(start, start-1) -
+			m.suggestionRows(start, start) +
+			m.suggestionRows(start-1, start-1)
+		if rows > m.config.PopupMaxHeight {
+			break
+		}
+
+		start--
+	}
+
+	return start
+}
+
+// suggestionRows returns the number of popup rows the suggestion at index i
+// is rendered on when the suggestion at index start is the first visible one.
+// This includes its description lines below it and a preceding group
+// separator.
+func (m PromptModel) suggestionRows(i, start int) int {
+	sugg := m.suggestions[i]
+	rows := 1
+
+	// The first description line is rendered inline unless descriptions
+	// are rendered below the suggestions.
+	if descLines := len(m.descriptionLines(sugg)); descLines > 0 {
+		rows += descLines
+		if !m.config.DescriptionBelow {
+			rows--
+		}
+	}
+
+	// Suggestions starting a new group are preceded by a separator,
+	// unless they are the first visible one.
+	if i > start && sugg.Group != m.suggestions[i-1].Group {
+		rows++
+	}
+
+	return rows
+}
+
+// descriptionLines returns the description of the given suggestion split into
+// the lines it is rendered on, wrapping it if DescriptionMaxWidth is set. No
+// lines are returned if descriptions are hidden or the suggestion has none.
+func (m PromptModel) descriptionLines(sugg Suggestion) []string {
+	if !m.config.ShowDescription || sugg.Description == "" {
+		return nil
+	}
+
+	if m.config.DescriptionMaxWidth > 0 {
+		return wrapString(
+			sugg.Description, m.config.DescriptionMaxWidth,
+		)
+	}
+
+	return []string{sugg.Description}
+}
+
+// renderOutput formats the output of the last executed command for display. It
+// trims trailing newlines to prevent double spacing, truncates lines wider
+// than the terminal if TruncateOutput is set and prefixes every line with the
+// primary prompt if PromptOutputLines is set. Output taller than
+// OutputMaxHeight is clipped to the window at the current scroll offset.
+func (m PromptModel) renderOutput() string {
+	outputLines := m.outputLines()
+
+	// Clip the output to the visible window if a maximum height is
+	// configured.
+	maxH := m.config.OutputMaxHeight
+	if maxH > 0 && len(outputLines) > maxH {
+		maxOffset := len(outputLines) - maxH
+		start := max(0, min(m.outputScrollOffset, maxOffset))
+		outputLines = outputLines[start : start+maxH]
+	}
+
+	// Determine the prefix for each output line.
+	prefix := ""
+	if m.config.PromptOutputLines {
+		_, prompt := splitPrompt(m.config.PromptPrimary)
+		prefix = m.config.Styles.Prompt.Render(prompt)
+	}
+
+	// Determine the maximum width of each output line, leaving room for
+	// the prefix. Zero means no truncation.
+	maxWidth := 0
+	if m.config.TruncateOutput {
+		maxWidth = m.outputWidth()
+	}
+
+	for i, line := range outputLines {
+		// Cut off overly wide lines with a rune-width aware ellipsis.
+		// ANSI escape sequences (e.g., colors) in the output don't
+		// count towards the width and are preserved.
+		if maxWidth > 0 {
+			line = ansi.TruncateWc(line, maxWidth, "…")
+		}
+
+		outputLines[i] = prefix + line
+	}
+
+	return strings.Join(outputLines, "\n")
+}
+
+// SetLine replaces the content of the line at the given row with text. Text
+// containing newlines is split into multiple lines that take the place of the
+// row, since every input line must be a single line. If the cursor is on that
+// row, its column is clamped to the new (first) line length (in runes) and any
+// autocomplete suggestions are cleared. Cursors on rows below move down along
+// with their lines. Out-of-range rows are ignored.
+func (m *PromptModel) SetLine(row int, text string) {
+	// Ignore rows outside of the current input.
+	if row < 0 || row >= len(m.lines) {
+		return
+	}
+
+	// Split multi-line text and insert the additional lines below the
+	// row.
+	parts := strings.Split(text, "\n")
+	added := len(parts) - 1
+	m.markEdited()
+	if added == 0 {
+		m.lines[row] = text
+	} else {
+		lines := make([]string, 0, len(m.lines)+added)
+		lines = append(lines, m.lines[:row]...)
+		lines = append(lines, parts...)
+		m.lines = append(lines, m.lines[row+1:]...)
+	}
+
+	// Keep additional cursors within the bounds of the replaced line and
+	// move those below it down with their lines.
+	for i, c := range m.extraCursors {
+		switch {
+		case c.row == row:
+			m.extraCursors[i].col = min(c.col, m.lineLen(row))
+
+		case c.row > row:
+			m.extraCursors[i].row += added
+		}
+	}
+	if m.blockAnchor.row > row {
+		m.blockAnchor.row += added
+	}
+	if m.cursorRow > row {
+		m.cursorRow += added
+	}
+
+	// Keep the cursor within the bounds of the replaced line.
+	if row == m.cursorRow {
+		m.cursorCol = min(m.cursorCol, m.lineLen(row))
+
+		// The previous suggestions no longer match the line content.
+		m.clearAutocomplete()
+	}
+}
+
+// Value returns the current input with lines joined by newlines. Trailing
+// blank lines are omitted, just like for the input that is executed.
+func (m *PromptModel) Value() string {
+	return m.ValueWithSep("\n")
+}
+
+// ValueWithSep returns the current input with lines joined by the given
+// separator (e.g., "\r\n"). Trailing blank lines are omitted.
+func (m *PromptModel) ValueWithSep(sep string) string {
+	return joinNonEmptyLines(m.lines, sep)
+}
+
+// InsertNewline splits the current line at the cursor, moving the cursor to
+// the start of the new line below, just like Enter does for incomplete input.
+// This allows parent applications to build their own key maps.
+func (m *PromptModel) InsertNewline() {
+	m.ensureNonEmpty()
+	m.insertNewline()
+	m.clearAutocomplete()
+}
+
+// IsEmpty returns whether the input is empty or consists only of whitespace.
+func (m *PromptModel) IsEmpty() bool {
+	return strings.TrimSpace(m.Value()) == ""
+}
+
+// SetPrompts updates the primary and secondary prompt strings at runtime. The
+// change takes effect on the next call to View.
+func (m *PromptModel) SetPrompts(primary, secondary string) {
+	m.config.PromptPrimary = primary
+	m.config.PromptSecondary = secondary
+}
+
+// HistoryMatching returns all history entries starting with the given prefix,
+// most recent first. An empty prefix returns the whole history.
+func (m *PromptModel) HistoryMatching(prefix string) []string {
+	matches := []string{}
+	for i := m.history.Len() - 1; i >= 0; i-- {
+		entry := m.history.At(i)
+		if strings.HasPrefix(entry, prefix) {
+			matches = append(matches, entry)
+		}
+	}
+
+	return matches
+}
+
+// LoadHistory replaces the history with the given entries, oldest first. The
+// entries are loaded through the configured store if it implements
+// HistoryReplacer, otherwise an in-memory store holding them takes its place.
+// Any in-progress history navigation ends, keeping the current input
+// editable, since the navigation index would no longer refer to the recalled
+// entry.
+func (m *PromptModel) LoadHistory(entries []string) {
+	m.replaceHistory(entries)
+}
+
+// Stash saves the current input as a draft and clears the input area, e.g., to
+// run another command in between. A previously stashed draft is replaced. Use
+// Unstash to restore the draft.
+func (m *PromptModel) Stash() {
+	m.stash = &draft{
+		lines:     append([]string{}, m.lines...),
+		cursorRow: m.cursorRow,
+		cursorCol: m.cursorCol,
+	}
+
+	// Reset the input state.
+	m.markEdited()
+	m.lines = []string{""}
+	m.cursorRow = 0
+	m.cursorCol = 0
+	m.historyIndex = -1
+	m.resetSelection()
+	m.clearAutocomplete()
+}
+
+// Unstash restores the draft saved by Stash, replacing the current input. It
+// does nothing if no draft has been stashed.
+func (m *PromptModel) Unstash() {
+	if m.stash == nil {
+		return
+	}
+
+	m.markEdited()
+	m.lines = m.stash.lines
+	m.cursorRow = m.stash.cursorRow
+	m.cursorCol = m.stash.cursorCol
+	m.stash = nil
+
+	// The restored input doesn't belong to the history.
+	m.historyIndex = -1
+	m.resetSelection()
+	m.clearAutocomplete()
+}
+
+// Snapshot returns a copy of the full editing state: the input, the cursor
+// position, the history and the displayed output.
+func (m *PromptModel) Snapshot() State {
+	history := make([]string, m.history.Len())
+	for i := range history {
+		history[i] = m.history.At(i)
+	}
+
+	return State{
+		Lines:     append([]string{}, m.lines...),
+		CursorRow: m.cursorRow,
+		CursorCol: m.cursorCol,
+		History:   history,
+		Output:    m.lastOutput,
+	}
+}
+
+// Restore replaces the editing state with the given snapshot. The history is
+// replaced through the configured store if it implements HistoryReplacer,
+// otherwise an in-memory store holding the snapshot's history takes its place.
+// The cursor is clamped to the restored input.
+func (m *PromptModel) Restore(state State) {
+	m.markEdited()
+	m.lines = append([]string{}, state.Lines...)
+	m.ensureNonEmpty()
+
+	m.cursorRow = max(0, min(state.CursorRow, len(m.lines)-1))
+	m.cursorCol = max(0, min(state.CursorCol, m.lineLen(m.cursorRow)))
+
+	m.replaceHistory(state.History)
+	m.setOutput(state.Output)
+
+	// Reset any transient state referring to the previous input.
+	m.resetSelection()
+	m.resetUndo()
+	m.clearAutocomplete()
+}
+
+// Focus marks the prompt as focused, rendering the cursor with the Cursor
+// style. Prompts are focused by default. Focusing an empty prompt shows the
+// configured SuggestionsOnEmpty, if any.
+func (m *PromptModel) Focus() {
+	m.blurred = false
+
+	m.showSuggestionsOnEmpty()
+}
+
+// Blur marks the prompt as not focused, rendering the cursor with the
+// BlurredCursor style and hiding the suggestion popup. This is useful when
+// multiple prompts share a screen.
+func (m *PromptModel) Blur() {
+	m.blurred = true
+
+	m.clearAutocomplete()
+}
+
+// Focused returns whether the prompt is currently focused.
+func (m *PromptModel) Focused() bool {
+	return !m.blurred
+}
+
+// SetEnabled enables or disables the whole prompt, e.g., while the application
+// is busy. While disabled, all key presses except Ctrl+C are ignored and the
+// input is rendered dimmed, without a cursor or suggestion popup.
+func (m *PromptModel) SetEnabled(enabled bool) {
+	m.disabled = !enabled
+
+	if !enabled {
+		m.clearAutocomplete()
+	}
+}
+
+// SetAutocompleteEnabled turns autocompletion on or off at runtime. While
+// disabled, the suggestion popup is never shown, regardless of typing.
+// Disabling hides any currently visible suggestions.
+func (m *PromptModel) SetAutocompleteEnabled(enabled bool) {
+	m.autocompleteDisabled = !enabled
+
+	if !enabled {
+		m.clearAutocomplete()
+	}
+}
+
+// RecordedKeys returns a copy of all key presses recorded so far. Keys are only
+// recorded if RecordKeys is set in the configuration.
+func (m *PromptModel) RecordedKeys() []tea.KeyMsg {
+	return append([]tea.KeyMsg{}, m.recordedKeys...)
+}
+
+// Replay feeds the given key presses to the model in order, as if they had
+// been typed by the user, including undo tracking and events. Replayed keys
+// are not recorded again and any commands they produce (e.g., tea.Quit) are
+// discarded.
+func (m *PromptModel) Replay(keys []tea.KeyMsg) {
+	m.replaying = true
+	defer func() {
+		m.replaying = false
+	}()
+
+	for _, key := range keys {
+		m.handleKeyMsg(key, time.Now())
+	}
+}
+
+// AcceptSuggestion selects the suggestion at the given index and applies it,
+// just like Tab does for the selected suggestion. It returns false if the popup
+// isn't visible or the index is out of range.
+func (m *PromptModel) AcceptSuggestion(index int) bool {
+	if !m.showPopup || index < 0 || index >= len(m.suggestions) {
+		return false
+	}
+
+	m.selectedSuggestionIndex = index
+	m.applyAutocomplete()
+
+	return true
+}
+
+// PopupVisible reports whether the suggestion popup is currently shown. This
+// allows parent models to avoid conflicting key handling while the popup is
+// open.
+func (m *PromptModel) PopupVisible() bool {
+	return m.showPopup
+}
+
+// PopupState returns the index of the selected suggestion, the index of the
+// first visible suggestion and the total number of suggestions. This allows
+// building custom popup renderers.
+func (m *PromptModel) PopupState() (selected, scrollOffset, total int) {
+	return m.selectedSuggestionIndex, m.popupScrollOffset,
+		len(m.suggestions)
+}
+
+// highlightMatch renders text with the runes matching fragment emphasized by
+// highlight and all other runes rendered with base. If text starts with
+// fragment (ignoring case), the prefix is highlighted. Otherwise, the runes
+// matching fragment as a subsequence (fuzzy match, ignoring case) are
+// highlighted. Text that doesn't match at all is rendered with base as a
+// whole. Runs of highlighted and other runes are rendered separately, so that
+// the end of a highlight doesn't reset the base style for the remaining runes.
+func highlightMatch(text, fragment string, base,
+	highlight lipgloss.Style) string {
+
+	textRunes := []rune(text)
+	fragmentRunes := []rune(fragment)
+	matched := make([]bool, len(textRunes))
+
+	switch {
+	// Nothing to highlight.
+	case len(fragmentRunes) == 0 || len(fragmentRunes) > len(textRunes):
+
+	// Prefer a prefix match, highlighting the matching prefix as a whole.
+	case strings.EqualFold(
+		string(textRunes[:len(fragmentRunes)]), fragment,
+	):
+		for i := range fragmentRunes {
+			matched[i] = true
+		}
+
+	// Otherwise, find the runes matching the fragment as a subsequence.
+	default:
+		next := 0
+		for i, r := range textRunes {
+			if next >= len(fragmentRunes) {
+				break
+			}
+
+			if unicode.ToLower(r) ==
+				unicode.ToLower(fragmentRunes[next]) {
+
+				matched[i] = true
+				next++
+			}
+		}
+
+		// Leave the text unhighlighted if the fragment doesn't
+		// match.
+		if next < len(fragmentRunes) {
+			clear(matched)
+		}
+	}
+
+	// Render each run of highlighted or other runes with its style.
+	var sb strings.Builder
+	for start := 0; start < len(textRunes); {
+		end := start
+		for end < len(textRunes) && matched[end] == matched[start] {
+			end++
+		}
+
+		style := base
+		if matched[start] {
+			style = highlight
+		}
+		sb.WriteString(style.Render(string(textRunes[start:end])))
+
+		start = end
+	}
+
+	return sb.String()
+}
+
+// textStyle returns a style holding only the text attributes of the given
+// style, i.e., its colors and emphasis, but none of its layout properties such
+// as padding or width. This allows applying the style of a line to each piece
+// of the line separately.
+func textStyle(style lipgloss.Style) lipgloss.Style {
+	return lipgloss.NewStyle().
+		Foreground(style.GetForeground()).
+		Background(style.GetBackground()).
+		Bold(style.GetBold()).
+		Italic(style.GetItalic()).
+		Underline(style.GetUnderline()).
+		Strikethrough(style.GetStrikethrough()).
+		Reverse(style.GetReverse()).
+		Blink(style.GetBlink()).
+		Faint(style.GetFaint())
+}
+
+// addScrollbar appends a vertical scrollbar column to the given rendered popup
+// lines. The size and position of the thumb reflect the number of visible
+// items relative to the total and the current scroll offset.
+func addScrollbar(lines []string, offset, visible, total int) []string {
+	rows := len(lines)
+	if rows == 0 || total <= visible {
+		return lines
+	}
+
+	// Size the thumb proportionally to the visible part of the list, but
+	// always show at least one row.
+	thumbSize := max(1, rows*visible/total)
+
+	// Position the thumb proportionally to the scroll offset, so that it
+	// touches the bottom when the end of the list is visible.
+	thumbStart := 0
+	if maxOffset := total - visible; maxOffset > 0 {
+		thumbStart = (rows - thumbSize) * min(offset, maxOffset) /
+			maxOffset
+	}
+
+	// Align the scrollbar by padding all lines to the same width.
+	lineWidth := 0
+	for _, line := range lines {
+		lineWidth = max(lineWidth, lipgloss.Width(line))
+	}
+
+	withScrollbar := make([]string, rows)
+	for i, line := range lines {
+		glyph := "│"
+		if i >= thumbStart && i < thumbStart+thumbSize {
+			glyph = "█"
+		}
+
+		withScrollbar[i] = padRight(line, lineWidth) + " " + glyph
+	}
+
+	return withScrollbar
+}
+
+// descriptionWidth returns the display width the description of the given
+// suggestion occupies in the popup, taking visibility and wrapping into
+// account.
+func (m PromptModel) descriptionWidth(sugg Suggestion) int {
+	if !m.config.ShowDescription || sugg.Description == "" {
+		return 0
+	}
+
+	width := runewidth.StringWidth(sugg.Description)
+
+	// Wrapped descriptions never exceed the configured maximum width.
+	if m.config.DescriptionMaxWidth > 0 {
+		width = min(width, m.config.DescriptionMaxWidth)
+	}
+
+	return width
+}
+
+// padRight pads s with spaces on the right so that its display width (ignoring
+// ANSI escape sequences) is at least width.
+func padRight(s string, width int) string {
+	padding := width - lipgloss.Width(s)
+	if padding <= 0 {
+		return s
+	}
+
+	return s + strings.Repeat(" ", padding)
+}
+
+// padStyled pads s on the right with spaces rendered with the given style, so
+// that its display width (ignoring ANSI escape sequences) is at least width.
+func padStyled(s string, width int, style lipgloss.Style) string {
+	padding := width - lipgloss.Width(s)
+	if padding <= 0 {
+		return s
+	}
+
+	return s + style.Render(strings.Repeat(" ", padding))
+}
+
+// wrapString word-wraps s into lines no wider than width display cells,
+// measured with runewidth. Words that are wider than width on their own are
+// broken at the width boundary. A width <= 0 returns s unchanged.
+func wrapString(s string, width int) []string {
+	if width <= 0 {
+		return []string{s}
+	}
+
+	var (
+		lines     []string
+		current   strings.Builder
+		currWidth int
+	)
+
+	// flush finishes the line currently being built.
+	flush := func() {
+		lines = append(lines, current.String())
+		current.Reset()
+		currWidth = 0
+	}
+
+	for _, word := range strings.Fields(s) {
+		wordWidth := runewidth.StringWidth(word)
+
+		// Start a new line if the word doesn't fit on the current one
+		// (accounting for the separating space).
+		if currWidth > 0 && currWidth+1+wordWidth > width {
+			flush()
+		}
+
+		// Add a separating space if the line already has content.
+		if currWidth > 0 {
+			current.WriteRune(' ')
+			currWidth++
+		}
+
+		// Break words that are wider than the full width rune by rune.
+		for _, r := range word {
+			runeWidth := runewidth.RuneWidth(r)
+			if currWidth > 0 && currWidth+runeWidth > width {
+				flush()
+			}
+			current.WriteRune(r)
+			currWidth += runeWidth
+		}
+	}
+
+	// Flush the last line, ensuring at least one line is returned.
+	if currWidth > 0 || len(lines) == 0 {
+		flush()
+	}
+
+	return lines
+}
+
+// collapseBlankLines removes all lines consisting only of whitespace from the
+// given input.
+func collapseBlankLines(input string) string {
+	lines := strings.Split(input, "\n")
+
+	nonBlank := lines[:0]
+	for _, line := range lines {
+		if strings.TrimSpace(line) != "" {
+			nonBlank = append(nonBlank, line)
+		}
+	}
+
+	return strings.Join(nonBlank, "\n")
+}
+
+// joinNonEmptyLines combines lines from a slice with the given separator,
+// removing any trailing lines that consist only of whitespace. Used before
+// executing a command.
+func joinNonEmptyLines(lines []string, sep string) string {
+	end := len(lines)
+
+	// Iterate backwards through the lines.
+	for end > 0 {
+		// Stop at the first line that contains non-whitespace
+		// characters.
+		if strings.TrimSpace(lines[end-1]) != "" {
+			break
+		}
+
+		// Decrement end pointer if the line is empty/whitespace.
+		end--
+	}
+
+	// Join the lines up to the last non-empty one found.
+	return strings.Join(lines[:end], sep)
+}
+
+// getCurrentInput is a convenience method on the model to get the processed
+// input string.
+func (m *PromptModel) getCurrentInput() string {
+	return joinNonEmptyLines(m.lines, "\n")
 }
 
 // cleanupExtraBlankLines removes consecutive blank lines specifically from the
 // *end* of the input lines slice. This prevents excessive blank lines during
-// input.
+// input. It does nothing if KeepTrailingBlankLines is set.
 func (m *PromptModel) cleanupExtraBlankLines() {
+	// Leave the layout untouched if the user wants to keep blank lines.
+	if m.config.KeepTrailingBlankLines {
+		return
+	}
+
 	// Loop while there are at least two lines and the last two are blank.
 	for len(m.lines) > 1 &&
 		strings.TrimSpace(m.lines[len(m.lines)-1]) == "" &&
 		strings.TrimSpace(m.lines[len(m.lines)-2]) == "" {
 		// Slice off the last line.
+		m.markEdited()
 		m.lines = m.lines[:len(m.lines)-1]
 	}
 }
@@ -1197,25 +4374,72 @@ func (m *PromptModel) currentWordFragment(isWordCharFn IsWordCharFunc) string {
 	if m.cursorRow >= len(m.lines) {
 		return ""
 	}
-	line := m.lines[m.cursorRow]
-	if m.cursorCol == 0 {
+
+	fragment, _ := WordFragmentAt(
+		m.lines[m.cursorRow], m.cursorCol, isWordCharFn,
+	)
+
+	return fragment
+}
+
+// WordUnderCursor returns the whole word the cursor is in or directly after,
+// expanding both left and right from the cursor using the configured
+// IsWordCharFn. Unlike the word fragment passed to the completer, it includes
+// the word characters after the cursor. It returns an empty string if the
+// cursor isn't next to a word.
+func (m *PromptModel) WordUnderCursor() string {
+	if len(m.lines) == 0 {
 		return ""
 	}
 
+	// Never index the line with an out-of-bounds cursor, without moving
+	// the cursor itself, since this is only a query.
+	row := max(0, min(m.cursorRow, len(m.lines)-1))
+	runes := []rune(m.lines[row])
+	col := max(0, min(m.cursorCol, len(runes)))
+	isWordChar := m.config.IsWordCharFn
+
+	// Expand to the left of the cursor.
+	start := col
+	for start > 0 && isWordChar(runes[start-1]) {
+		start--
+	}
+
+	// Expand to the right of the cursor.
+	end := col
+	for end < len(runes) && isWordChar(runes[end]) {
+		end++
+	}
+
+	return string(runes[start:end])
+}
+
+// WordFragmentAt identifies the sequence of "word" characters (as defined by
+// isWordChar) ending at the given rune column of line. It returns the fragment
+// and the rune index where it starts. If no fragment ends at col (e.g., the
+// preceding character is a space or col is out of bounds), an empty fragment is
+// returned and start equals col clamped to the bounds of the line.
+func WordFragmentAt(line string, col int,
+	isWordChar IsWordCharFunc) (fragment string, start int) {
+
 	// Work with runes for multi-byte character safety.
 	lineRunes := []rune(line)
+
 	// Check column bounds against rune count.
-	if m.cursorCol > len(lineRunes) {
-		return ""
+	if col <= 0 {
+		return "", 0
+	}
+	if col > len(lineRunes) {
+		return "", len(lineRunes)
 	}
 
-	// Scan backwards from the cursor column to find the start of the word
+	// Scan backwards from the column to find the start of the word
 	// fragment.
-	start := m.cursorCol
+	start = col
 	for start > 0 {
-		// Use the configured function to check if the character is part
-		// of a word.
-		if isWordCharFn(lineRunes[start-1]) {
+		// Use the given function to check if the character is part of
+		// a word.
+		if isWordChar(lineRunes[start-1]) {
 			// Continue scanning left.
 			start--
 		} else {
@@ -1224,17 +4448,13 @@ func (m *PromptModel) currentWordFragment(isWordCharFn IsWordCharFunc) string {
 		}
 	}
 
-	// Verify that a fragment was actually found (start < cursorCol) and
-	// that the character *immediately* before the cursor is indeed a word
-	// character. This prevents matching if the cursor is right after a
-	// space, e.g., "SELECT |".
-	if start < m.cursorCol && m.cursorCol > 0 &&
-		isWordCharFn(lineRunes[m.cursorCol-1]) {
-
-		// Return the identified word fragment as a string.
-		return string(lineRunes[start:m.cursorCol])
+	// No word characters were found immediately before the column. This
+	// prevents matching if the column is right after a space, e.g.,
+	// "SELECT |".
+	if start == col {
+		return "", col
 	}
 
-	// No valid word fragment found ending at the cursor.
-	return ""
+	// Return the identified word fragment as a string.
+	return string(lineRunes[start:col]), start
 }
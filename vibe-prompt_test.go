@@ -0,0 +1,3482 @@
+package vprompt
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+	"github.com/muesli/termenv"
+)
+
+// typeText feeds every rune of the given text to the model as a separate key
+// press, just like a user typing it. Spaces are sent as tea.KeySpace and
+// newlines as tea.KeyEnter.
+func typeText(m *PromptModel, text string) {
+	for _, r := range text {
+		msg := tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{r}}
+		switch r {
+		case ' ':
+			msg.Type = tea.KeySpace
+
+		case '\n':
+			msg = tea.KeyMsg{Type: tea.KeyEnter}
+		}
+
+		m.Update(msg)
+	}
+}
+
+// pressKeys feeds the given keys to the model in order.
+func pressKeys(m *PromptModel, keys ...tea.KeyType) {
+	for _, key := range keys {
+		m.Update(tea.KeyMsg{Type: key})
+	}
+}
+
+// TestOutputChunks asserts that streamed output chunks are appended to the
+// displayed output, survive edits while streaming and are finalized by an
+// OutputDoneMsg.
+func TestOutputChunks(t *testing.T) {
+	m := NewPromptModel(PromptConfig{})
+
+	for _, chunk := range []string{"one ", "two ", "three"} {
+		m.Update(OutputChunkMsg(chunk))
+	}
+	if m.lastOutput != "one two three" {
+		t.Fatalf("expected concatenated chunks, got %q", m.lastOutput)
+	}
+
+	// Edits must not clear the output while it is being streamed.
+	typeText(m, "x")
+	if m.lastOutput != "one two three" {
+		t.Fatalf("output cleared while streaming: %q", m.lastOutput)
+	}
+
+	m.Update(OutputDoneMsg{})
+	if m.streaming {
+		t.Fatalf("expected streaming to end after OutputDoneMsg")
+	}
+
+	// Once finalized, the next edit clears the output as usual.
+	typeText(m, "y")
+	if m.lastOutput != "" {
+		t.Fatalf("expected output to be cleared, got %q", m.lastOutput)
+	}
+}
+
+// TestExecuteEndsStreaming asserts that submitting a new command while a
+// previous stream is still open ends the streaming state.
+func TestExecuteEndsStreaming(t *testing.T) {
+	m := NewPromptModel(PromptConfig{
+		ExecuteAsyncFn: func(string) tea.Cmd { return nil },
+	})
+
+	m.Update(OutputChunkMsg("partial"))
+	typeText(m, "SELECT 1;\n")
+
+	if m.streaming {
+		t.Fatalf("expected a new execution to end streaming")
+	}
+}
+
+// TestControlCharPolicy asserts that control characters in pasted input are
+// dropped, escaped or kept according to the configured policy, while newlines
+// and tabs are always dropped.
+func TestControlCharPolicy(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy ControlCharPolicy
+		want   string
+	}{
+		{name: "drop", policy: ControlCharDrop, want: "ab"},
+		{name: "escape", policy: ControlCharEscape, want: "a^Ab"},
+		{name: "keep", policy: ControlCharKeep, want: "a\x01b"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			m := NewPromptModel(PromptConfig{
+				ControlChars: test.policy,
+			})
+
+			m.Update(tea.KeyMsg{
+				Type:  tea.KeyRunes,
+				Runes: []rune("a\x01\t\nb"),
+				Paste: true,
+			})
+
+			if m.lines[0] != test.want {
+				t.Fatalf("expected %q, got %q", test.want,
+					m.lines[0])
+			}
+			if m.cursorCol != len(m.lines[0]) {
+				t.Fatalf("expected cursor at end, got %d",
+					m.cursorCol)
+			}
+		})
+	}
+}
+
+// numberedCompleter returns an AutoCompleteFunc that suggests n words, each
+// starting with the typed word fragment followed by a two digit number.
+func numberedCompleter(n int) AutoCompleteFunc {
+	return func(_, word string) []Suggestion {
+		suggs := make([]Suggestion, n)
+		for i := range suggs {
+			suggs[i] = Suggestion{
+				Text: fmt.Sprintf("%s%02d", word, i),
+			}
+		}
+
+		return suggs
+	}
+}
+
+// TestPopupPaging asserts that Page Up and Page Down move the selection and
+// the scroll offset by a page of suggestions, clamping at both ends.
+func TestPopupPaging(t *testing.T) {
+	m := NewPromptModel(PromptConfig{
+		AutoCompleteFn: numberedCompleter(30),
+		PopupMaxHeight: 6,
+	})
+	typeText(m, "a")
+
+	steps := []struct {
+		key      tea.KeyType
+		selected int
+		offset   int
+	}{
+		{key: tea.KeyPgDown, selected: 6, offset: 6},
+		{key: tea.KeyPgDown, selected: 12, offset: 12},
+		{key: tea.KeyPgDown, selected: 18, offset: 18},
+		{key: tea.KeyPgDown, selected: 24, offset: 24},
+		{key: tea.KeyPgDown, selected: 29, offset: 24},
+		{key: tea.KeyPgDown, selected: 29, offset: 24},
+		{key: tea.KeyPgUp, selected: 23, offset: 18},
+		{key: tea.KeyPgUp, selected: 17, offset: 12},
+		{key: tea.KeyPgUp, selected: 11, offset: 6},
+		{key: tea.KeyPgUp, selected: 5, offset: 0},
+		{key: tea.KeyPgUp, selected: 0, offset: 0},
+	}
+
+	for i, step := range steps {
+		pressKeys(m, step.key)
+
+		selected := m.selectedSuggestionIndex
+		offset := m.popupScrollOffset
+		if selected != step.selected || offset != step.offset ||
+			len(m.suggestions) != 30 {
+
+			t.Fatalf("step %d: expected selection %d at offset "+
+				"%d, got %d at %d", i, step.selected,
+				step.offset, selected, offset)
+		}
+	}
+}
+
+// TestPromptOutputLines asserts that every output line is prefixed with the
+// primary prompt if PromptOutputLines is set.
+func TestPromptOutputLines(t *testing.T) {
+	m := NewPromptModel(PromptConfig{
+		PromptPrimary:     "> ",
+		PromptOutputLines: true,
+		ExecuteFn: func(string) string {
+			return "first\nsecond"
+		},
+	})
+	typeText(m, "SELECT 1;\n")
+
+	if !strings.Contains(m.View(), "> first\n> second\n") {
+		t.Fatalf("expected prefixed output lines, got %q", m.View())
+	}
+}
+
+// TestWordFragmentAt asserts that WordFragmentAt returns the word fragment
+// ending at the given rune column and its start for various lines.
+func TestWordFragmentAt(t *testing.T) {
+	tests := []struct {
+		name     string
+		line     string
+		col      int
+		fragment string
+		start    int
+	}{
+		{name: "empty line", line: "", col: 0},
+		{name: "line start", line: "SELECT", col: 0},
+		{
+			name: "whole word", line: "SELECT", col: 6,
+			fragment: "SELECT",
+		},
+		{name: "mid word", line: "SELECT", col: 3, fragment: "SEL"},
+		{
+			name: "second word", line: "SELECT na", col: 9,
+			fragment: "na", start: 7,
+		},
+		{name: "after space", line: "SELECT ", col: 7, start: 7},
+		{
+			name: "after punctuation", line: "f(ab", col: 4,
+			fragment: "ab", start: 2,
+		},
+		{
+			name: "period is word char", line: "t.col", col: 5,
+			fragment: "t.col",
+		},
+		{
+			name: "multi-byte runes", line: "ä ñö", col: 4,
+			fragment: "ñö", start: 2,
+		},
+		{name: "negative column", line: "abc", col: -1},
+		{name: "past the end", line: "abc", col: 10, start: 3},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fragment, start := WordFragmentAt(
+				test.line, test.col, DefaultIsWordChar,
+			)
+			if fragment != test.fragment || start != test.start {
+				t.Fatalf("expected (%q, %d), got (%q, %d)",
+					test.fragment, test.start, fragment,
+					start)
+			}
+		})
+	}
+}
+
+// TestDescriptionMaxWidth asserts that a description longer than
+// DescriptionMaxWidth is wrapped onto the line below its suggestion.
+func TestDescriptionMaxWidth(t *testing.T) {
+	m := NewPromptModel(PromptConfig{
+		AutoCompleteFn: func(_, _ string) []Suggestion {
+			return []Suggestion{{
+				Text: "abc", Description: "wrapped description",
+			}}
+		},
+		ShowDescription:     true,
+		DescriptionMaxWidth: 12,
+	})
+	typeText(m, "a")
+
+	// The popup follows the input line in the rendered view.
+	lines := strings.Split(m.View(), "\n")
+	row := -1
+	for i, line := range lines {
+		if strings.Contains(line, "abc  wrapped") {
+			row = i
+			break
+		}
+	}
+	if row < 0 || row+1 >= len(lines) ||
+		!strings.Contains(lines[row+1], "description") {
+
+		t.Fatalf("expected wrapped description, got %q", lines)
+	}
+
+	// The continuation is aligned with the description column.
+	if strings.Index(lines[row+1], "description") !=
+		strings.Index(lines[row], "wrapped") {
+
+		t.Fatalf("expected aligned description, got %q", lines)
+	}
+}
+
+// TestStablePopupWidth asserts that the popup keeps its width while scrolling
+// through suggestions of varying widths if StablePopupWidth is set.
+func TestStablePopupWidth(t *testing.T) {
+	completer := func(_, _ string) []Suggestion {
+		return []Suggestion{
+			{Text: "a"}, {Text: "ab"}, {Text: "abc"},
+			{Text: "abcd"}, {Text: "abcde"},
+			{Text: "abcdefghijklmnop"},
+		}
+	}
+
+	for _, stable := range []bool{false, true} {
+		m := NewPromptModel(PromptConfig{
+			AutoCompleteFn:   completer,
+			PopupMaxHeight:   2,
+			StablePopupWidth: stable,
+		})
+		typeText(m, "a")
+
+		widths := map[int]struct{}{}
+		for i := 0; i < 6; i++ {
+			widths[lipgloss.Width(m.View())] = struct{}{}
+			pressKeys(m, tea.KeyDown)
+		}
+
+		if stable && len(widths) != 1 {
+			t.Fatalf("expected constant width, got %v", widths)
+		}
+		if !stable && len(widths) == 1 {
+			t.Fatalf("expected width to follow visible items")
+		}
+	}
+}
+
+// TestSetLine asserts that SetLine replaces the line rune-safely, clamps the
+// cursor on the replaced line, ignores out-of-range rows and splits text
+// containing newlines into separate lines.
+func TestSetLine(t *testing.T) {
+	m := NewPromptModel(PromptConfig{})
+	typeText(m, "first\nsecond line")
+
+	// Replacing the cursor's line with a shorter one clamps the cursor.
+	m.SetLine(1, "äö")
+	if m.lines[1] != "äö" || m.cursorRow != 1 || m.cursorCol != 2 {
+		t.Fatalf("expected clamped cursor, got %q at %d:%d", m.lines,
+			m.cursorRow, m.cursorCol)
+	}
+
+	// Out-of-range rows are ignored.
+	m.SetLine(-1, "x")
+	m.SetLine(2, "x")
+	if len(m.lines) != 2 || m.lines[0] != "first" {
+		t.Fatalf("expected lines unchanged, got %q", m.lines)
+	}
+
+	// Text with newlines becomes multiple lines and the cursor below
+	// moves down with its line.
+	m.SetLine(0, "a\nb")
+	want := []string{"a", "b", "äö"}
+	if strings.Join(m.lines, "|") != strings.Join(want, "|") {
+		t.Fatalf("expected %q, got %q", want, m.lines)
+	}
+	if m.cursorRow != 2 || m.cursorCol != 2 {
+		t.Fatalf("expected cursor to follow its line, got %d:%d",
+			m.cursorRow, m.cursorCol)
+	}
+}
+
+// TestTruncateOutput asserts that output lines wider than the terminal are
+// truncated to its width with an ellipsis, measuring wide runes correctly.
+func TestTruncateOutput(t *testing.T) {
+	m := NewPromptModel(PromptConfig{
+		TruncateOutput: true,
+		ExecuteFn: func(string) string {
+			return "漢字漢字漢字漢字漢字"
+		},
+	})
+	m.Update(tea.WindowSizeMsg{Width: 9, Height: 10})
+	typeText(m, "SELECT 1;\n")
+
+	lines := strings.Split(m.renderOutput(), "\n")
+	for _, line := range lines {
+		if width := lipgloss.Width(line); width > 9 {
+			t.Fatalf("line %q exceeds width: %d", line, width)
+		}
+	}
+	if lines[2] != "漢字漢字…" {
+		t.Fatalf("expected truncated CJK line, got %q", lines[2])
+	}
+}
+
+// TestSetAutocompleteEnabled asserts that disabling autocompletion hides the
+// popup and keeps it hidden while typing matching input.
+func TestSetAutocompleteEnabled(t *testing.T) {
+	m := NewPromptModel(PromptConfig{
+		AutoCompleteFn: numberedCompleter(3),
+	})
+	typeText(m, "a")
+	if !m.showPopup {
+		t.Fatalf("expected popup for matching input")
+	}
+
+	m.SetAutocompleteEnabled(false)
+	if m.showPopup {
+		t.Fatalf("expected disabling to hide the popup")
+	}
+
+	typeText(m, "b")
+	m.updateAutocomplete()
+	if m.showPopup {
+		t.Fatalf("expected popup to stay hidden while disabled")
+	}
+
+	m.SetAutocompleteEnabled(true)
+	typeText(m, "c")
+	if !m.showPopup {
+		t.Fatalf("expected popup after enabling again")
+	}
+}
+
+// TestBackspaceGraphemeCluster asserts that a single Backspace deletes a whole
+// grapheme cluster, such as an emoji with a skin tone modifier or a ZWJ
+// sequence.
+func TestBackspaceGraphemeCluster(t *testing.T) {
+	tests := []struct {
+		name    string
+		cluster string
+	}{
+		{name: "skin tone", cluster: "👍🏽"},
+		{name: "zwj sequence", cluster: "👨‍👩‍👧"},
+		{name: "combining mark", cluster: "e\u0301"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			m := NewPromptModel(PromptConfig{})
+			m.Update(tea.KeyMsg{
+				Type:  tea.KeyRunes,
+				Runes: []rune("a" + test.cluster),
+			})
+
+			pressKeys(m, tea.KeyBackspace)
+			if m.lines[0] != "a" || m.cursorCol != 1 {
+				t.Fatalf("expected cluster removed, got %q "+
+					"at %d", m.lines[0], m.cursorCol)
+			}
+		})
+	}
+}
+
+// TestCaseTransform asserts that typed and pasted letters are converted to the
+// configured case, leaving other runes untouched.
+func TestCaseTransform(t *testing.T) {
+	tests := []struct {
+		name      string
+		transform CaseTransform
+		want      string
+	}{
+		{name: "none", transform: CaseNone, want: "select 1 Ää"},
+		{name: "upper", transform: CaseUpper, want: "SELECT 1 ÄÄ"},
+		{name: "lower", transform: CaseLower, want: "select 1 ää"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			m := NewPromptModel(PromptConfig{
+				CaseTransform: test.transform,
+			})
+
+			// Type the first part and paste the rest.
+			typeText(m, "select 1 ")
+			m.Update(tea.KeyMsg{
+				Type:  tea.KeyRunes,
+				Runes: []rune("Ää"),
+				Paste: true,
+			})
+
+			if m.lines[0] != test.want {
+				t.Fatalf("expected %q, got %q", test.want,
+					m.lines[0])
+			}
+		})
+	}
+}
+
+// TestTextAfterCursor asserts that TextAfterCursor returns everything from the
+// cursor to the end of a multi-line buffer, rune-safely.
+func TestTextAfterCursor(t *testing.T) {
+	m := NewPromptModel(PromptConfig{})
+	m.lines = []string{"SELECT", "äöü FROM", "table"}
+
+	// Place the cursor into the middle of the second line.
+	m.cursorRow, m.cursorCol = 1, 4
+	if got := m.TextAfterCursor(); got != "FROM\ntable" {
+		t.Fatalf("expected text after cursor, got %q", got)
+	}
+	if got := m.getTextBeforeCursor(); got != "SELECT\näöü " {
+		t.Fatalf("expected text before cursor, got %q", got)
+	}
+}
+
+// TestAutoCompleteCtxFn asserts that a context based completer receives the
+// full completion context and takes precedence over AutoCompleteFn.
+func TestAutoCompleteCtxFn(t *testing.T) {
+	var ctx CompletionContext
+	m := NewPromptModel(PromptConfig{
+		AutoCompleteCtxFn: func(c CompletionContext) []Suggestion {
+			ctx = c
+			return []Suggestion{{Text: "name"}}
+		},
+		AutoCompleteFn: func(_, _ string) []Suggestion {
+			t.Fatalf("AutoCompleteFn must not be called")
+			return nil
+		},
+	})
+	typeText(m, "SELECT\nna FROM t")
+
+	// Move the cursor right after "na".
+	for i := 0; i < len(" FROM t"); i++ {
+		pressKeys(m, tea.KeyLeft)
+	}
+	m.updateAutocomplete()
+
+	if ctx.TextBeforeCursor != "SELECT\nna" ||
+		ctx.TextAfterCursor != " FROM t" ||
+		ctx.WordFragment != "na" || ctx.CursorRow != 1 ||
+		ctx.CursorCol != 2 || len(ctx.Lines) != 2 {
+
+		t.Fatalf("unexpected context: %+v", ctx)
+	}
+	if !m.showPopup {
+		t.Fatalf("expected popup with context suggestions")
+	}
+
+	// The completer can't modify the input through the context.
+	ctx.Lines[0] = "changed"
+	if m.lines[0] != "SELECT" {
+		t.Fatalf("expected input to be unaffected, got %q", m.lines)
+	}
+}
+
+// suggestionTexts returns the texts of the current suggestions of the model.
+func suggestionTexts(m *PromptModel) []string {
+	texts := make([]string, len(m.suggestions))
+	for i, sugg := range m.suggestions {
+		texts[i] = sugg.Text
+	}
+
+	return texts
+}
+
+// fixedCompleter returns an AutoCompleteFunc that always suggests the given
+// texts in the given order.
+func fixedCompleter(texts ...string) AutoCompleteFunc {
+	return func(_, _ string) []Suggestion {
+		suggs := make([]Suggestion, len(texts))
+		for i, text := range texts {
+			suggs[i] = Suggestion{Text: text}
+		}
+
+		return suggs
+	}
+}
+
+// TestSortSuggestions asserts that suggestions keep the completer's order by
+// default and are rendered sorted if SortSuggestions is set, optionally with a
+// custom order.
+func TestSortSuggestions(t *testing.T) {
+	tests := []struct {
+		name string
+		sort bool
+		less SuggestionLessFunc
+		want string
+	}{
+		{name: "unsorted", want: "ac|ab|abc"},
+		{name: "alphabetical", sort: true, want: "ab|abc|ac"},
+		{
+			name: "custom", sort: true,
+			less: func(a, b Suggestion) bool {
+				return len(a.Text) > len(b.Text)
+			},
+			want: "abc|ac|ab",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			m := NewPromptModel(PromptConfig{
+				AutoCompleteFn: fixedCompleter(
+					"ac", "ab", "abc",
+				),
+				SortSuggestions: test.sort,
+				SuggestionLess:  test.less,
+			})
+			typeText(m, "a")
+
+			got := strings.Join(suggestionTexts(m), "|")
+			if got != test.want {
+				t.Fatalf("expected %q, got %q", test.want, got)
+			}
+
+			// The popup renders the suggestions in that order.
+			popup := m.renderPopup()
+			for i, text := range strings.Split(test.want, "|") {
+				line := strings.Split(popup, "\n")[i]
+				if strings.TrimSpace(line) != text {
+					t.Fatalf("expected %q on line %d, got "+
+						"%q", text, i, line)
+				}
+			}
+		})
+	}
+}
+
+// TestDedupSuggestions asserts that suggestions with duplicate Text are
+// removed if DedupSuggestions is set, keeping the first occurrence in order.
+func TestDedupSuggestions(t *testing.T) {
+	for _, dedup := range []bool{false, true} {
+		m := NewPromptModel(PromptConfig{
+			AutoCompleteFn: func(_, _ string) []Suggestion {
+				return []Suggestion{
+					{Text: "ab", Description: "first"},
+					{Text: "ac"},
+					{Text: "ab", Description: "second"},
+					{Text: "ac"},
+				}
+			},
+			DedupSuggestions: dedup,
+		})
+		typeText(m, "a")
+
+		got := strings.Join(suggestionTexts(m), "|")
+		want := "ab|ac|ab|ac"
+		if dedup {
+			want = "ab|ac"
+		}
+		if got != want {
+			t.Fatalf("dedup %v: expected %q, got %q", dedup, want,
+				got)
+		}
+		if m.suggestions[0].Description != "first" {
+			t.Fatalf("expected first occurrence to be kept")
+		}
+	}
+}
+
+// activeBackgrounds returns, for each visible rune of s, whether the given SGR
+// background parameter was active when the rune was written.
+func activeBackgrounds(s, background string) []bool {
+	var (
+		active []bool
+		on     bool
+	)
+	for i := 0; i < len(s); {
+		// Track the background through the SGR sequences, which are
+		// the only escape sequences lipgloss emits here.
+		if strings.HasPrefix(s[i:], "\x1b[") {
+			end := strings.IndexByte(s[i:], 'm')
+			params := s[i+2 : i+end]
+			switch {
+			case params == "" || params == "0":
+				on = false
+
+			case strings.Contains(params, background):
+				on = true
+			}
+			i += end + 1
+
+			continue
+		}
+
+		_, size := utf8.DecodeRuneInString(s[i:])
+		active = append(active, on)
+		i += size
+	}
+
+	return active
+}
+
+// TestHighlightKeepsRowStyle tests that the highlighted match of the selected
+// suggestion doesn't reset the row's background for the rest of the row.
+func TestHighlightKeepsRowStyle(t *testing.T) {
+	profile := lipgloss.ColorProfile()
+	lipgloss.SetColorProfile(termenv.TrueColor)
+	defer lipgloss.SetColorProfile(profile)
+
+	styles := DefaultPromptStyles()
+	styles.PopupBox = lipgloss.NewStyle()
+	m := NewPromptModel(PromptConfig{
+		AutoCompleteFn: func(_, _ string) []Suggestion {
+			return []Suggestion{
+				{Text: "select", Description: "query"},
+				{Text: "sum", Description: "aggregate"},
+			}
+		},
+		Styles: styles,
+	})
+	typeText(m, "se")
+	if !m.showPopup || m.selectedSuggestionIndex != 0 {
+		t.Fatalf("expected the first suggestion to be selected")
+	}
+
+	popup := m.renderPopup()
+	row := strings.Split(popup, "\n")[0]
+	if !strings.Contains(row, "\x1b[1") {
+		t.Fatalf("expected the match to be highlighted: %q", row)
+	}
+
+	for i, on := range activeBackgrounds(row, "48;5;60") {
+		if !on {
+			t.Fatalf("row background lost at column %d: %q", i,
+				row)
+		}
+	}
+}
+
+// TestPopupVisible tests that PopupVisible reflects the popup being shown and
+// hidden as the suggestions change.
+func TestPopupVisible(t *testing.T) {
+	m := NewPromptModel(PromptConfig{
+		AutoCompleteFn: fixedCompleter("select", "sum"),
+	})
+	if m.PopupVisible() {
+		t.Fatalf("expected no popup initially")
+	}
+
+	typeText(m, "s")
+	if !m.PopupVisible() {
+		t.Fatalf("expected popup to be visible")
+	}
+
+	typeText(m, " ")
+	if m.PopupVisible() {
+		t.Fatalf("expected popup to be hidden after a space")
+	}
+
+	typeText(m, "s")
+	if !m.PopupVisible() {
+		t.Fatalf("expected popup to be visible again")
+	}
+
+	pressKeys(m, tea.KeyTab)
+	if m.PopupVisible() {
+		t.Fatalf("expected popup to be hidden after accepting")
+	}
+}
+
+// TestOnOutputComplete tests that OnOutputComplete fires once asynchronous
+// output has arrived, but not for streamed chunks that aren't finalized yet.
+func TestOnOutputComplete(t *testing.T) {
+	calls := 0
+	m := NewPromptModel(PromptConfig{
+		OnOutputComplete: func() {
+			calls++
+		},
+	})
+
+	m.Update(OutputMsg("done"))
+	if calls != 1 {
+		t.Fatalf("expected 1 call after OutputMsg, got %d", calls)
+	}
+
+	m.Update(OutputChunkMsg("part"))
+	if calls != 1 {
+		t.Fatalf("expected no call for a chunk, got %d", calls-1)
+	}
+
+	m.Update(OutputDoneMsg{})
+	if calls != 2 {
+		t.Fatalf("expected 2 calls after OutputDoneMsg, got %d", calls)
+	}
+}
+
+// TestRecordAndReplayKeys tests that replaying the recorded key presses on a
+// fresh model leads to the same final state.
+func TestRecordAndReplayKeys(t *testing.T) {
+	m := NewPromptModel(PromptConfig{RecordKeys: true})
+	typeText(m, "SELECT 12\nFROM")
+	pressKeys(m, tea.KeyUp, tea.KeyLeft, tea.KeyBackspace)
+
+	keys := m.RecordedKeys()
+	if len(keys) != 17 {
+		t.Fatalf("expected 17 recorded keys, got %d", len(keys))
+	}
+
+	replayed := NewPromptModel(PromptConfig{RecordKeys: true})
+	replayed.Replay(keys)
+
+	got, want := replayed.getCurrentInput(), m.getCurrentInput()
+	if got != want {
+		t.Fatalf("expected input %q, got %q", want, got)
+	}
+	if replayed.cursorRow != m.cursorRow ||
+		replayed.cursorCol != m.cursorCol {
+
+		t.Fatalf("expected cursor %d:%d, got %d:%d", m.cursorRow,
+			m.cursorCol, replayed.cursorRow, replayed.cursorCol)
+	}
+	if len(replayed.RecordedKeys()) != 0 {
+		t.Fatalf("expected replayed keys not to be recorded")
+	}
+
+	// Keys aren't recorded unless configured.
+	plain := NewPromptModel(PromptConfig{})
+	typeText(plain, "a")
+	if len(plain.RecordedKeys()) != 0 {
+		t.Fatalf("expected no recorded keys")
+	}
+}
+
+// TestOnIdle tests that OnIdle is called with the current input when the idle
+// tick of the last key press fires, but not for ticks superseded by later key
+// presses.
+func TestOnIdle(t *testing.T) {
+	var inputs []string
+	m := NewPromptModel(PromptConfig{
+		OnIdle: func(input string) {
+			inputs = append(inputs, input)
+		},
+		IdleTimeout: time.Second,
+	})
+
+	_, cmd := m.Update(tea.KeyMsg{
+		Type: tea.KeyRunes, Runes: []rune("a"),
+	})
+	if cmd == nil {
+		t.Fatalf("expected an idle tick to be scheduled")
+	}
+	stale := idleTickMsg{seq: m.keySeq}
+
+	typeText(m, "b")
+	m.Update(stale)
+	if len(inputs) != 0 {
+		t.Fatalf("expected stale tick to be ignored, got %v", inputs)
+	}
+
+	// Simulate the tick of the last key press firing after a quiet
+	// period.
+	m.Update(idleTickMsg{seq: m.keySeq})
+	if len(inputs) != 1 || inputs[0] != "ab" {
+		t.Fatalf("expected OnIdle(\"ab\"), got %v", inputs)
+	}
+}
+
+// scrollbarColumn returns the last glyph of every rendered popup line, which
+// holds the scrollbar if one is shown.
+func scrollbarColumn(m *PromptModel) string {
+	var column strings.Builder
+	for _, line := range strings.Split(m.renderPopup(), "\n") {
+		runes := []rune(strings.TrimRight(line, " "))
+		if len(runes) > 0 {
+			column.WriteRune(runes[len(runes)-1])
+		}
+	}
+
+	return column.String()
+}
+
+// TestPopupScrollbar tests that the scrollbar thumb follows the popup's scroll
+// offset and that no scrollbar is shown unless configured.
+func TestPopupScrollbar(t *testing.T) {
+	styles := DefaultPromptStyles()
+	styles.PopupBox = lipgloss.NewStyle()
+	m := NewPromptModel(PromptConfig{
+		AutoCompleteFn:     numberedCompleter(12),
+		PopupMaxHeight:     4,
+		ShowPopupScrollbar: true,
+		Styles:             styles,
+	})
+	typeText(m, "a")
+
+	if got := scrollbarColumn(m); got != "█│││" {
+		t.Fatalf("expected thumb at the top, got %q", got)
+	}
+
+	pressKeys(m, tea.KeyPgDown)
+	if got := scrollbarColumn(m); got != "│█││" {
+		t.Fatalf("expected thumb in the middle, got %q", got)
+	}
+
+	pressKeys(m, tea.KeyPgDown, tea.KeyPgDown)
+	if got := scrollbarColumn(m); got != "│││█" {
+		t.Fatalf("expected thumb at the bottom, got %q", got)
+	}
+
+	m.config.ShowPopupScrollbar = false
+	if got := scrollbarColumn(m); strings.ContainsAny(got, "│█") {
+		t.Fatalf("expected no scrollbar, got %q", got)
+	}
+}
+
+// TestSubmit tests that Submit executes and resets a complete buffer, and that
+// incomplete input is only rejected if SubmitChecksComplete is set.
+func TestSubmit(t *testing.T) {
+	tests := []struct {
+		name          string
+		input         string
+		checkComplete bool
+		executed      bool
+	}{{
+		name:     "complete",
+		input:    "SELECT 1;",
+		executed: true,
+	}, {
+		name:     "incomplete without check",
+		input:    "SELECT 1",
+		executed: true,
+	}, {
+		name:          "incomplete with check",
+		input:         "SELECT 1",
+		checkComplete: true,
+	}, {
+		name:          "complete with check",
+		input:         "SELECT 1;",
+		checkComplete: true,
+		executed:      true,
+	}, {
+		name:  "blank",
+		input: "  ",
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var executed []string
+			m := NewPromptModel(PromptConfig{
+				ExecuteFn: func(input string) string {
+					executed = append(executed, input)
+					return "ok"
+				},
+				SubmitChecksComplete: test.checkComplete,
+			})
+			typeText(m, test.input)
+
+			m.Submit()
+			if !test.executed {
+				if len(executed) != 0 {
+					t.Fatalf("expected no execution")
+				}
+				if m.history.Len() != 0 {
+					t.Fatalf("expected no history entry")
+				}
+
+				return
+			}
+
+			if len(executed) != 1 || executed[0] != test.input {
+				t.Fatalf("expected %q to be executed, got %v",
+					test.input, executed)
+			}
+			if m.getCurrentInput() != "" || m.cursorCol != 0 {
+				t.Fatalf("expected input to be reset")
+			}
+			if m.history.Len() != 1 {
+				t.Fatalf("expected a history entry")
+			}
+		})
+	}
+}
+
+// TestEnsureNonEmpty tests that aggressive deletes, even on a model whose lines
+// were emptied, never panic and always leave a single line behind.
+func TestEnsureNonEmpty(t *testing.T) {
+	deletes := []tea.KeyType{
+		tea.KeyBackspace, tea.KeyDelete, tea.KeyCtrlW, tea.KeyCtrlU,
+		tea.KeyCtrlK, tea.KeyCtrlD, tea.KeyCtrlH,
+	}
+
+	for _, key := range deletes {
+		m := NewPromptModel(PromptConfig{})
+		typeText(m, "ab\ncd")
+		for range 8 {
+			pressKeys(m, key, tea.KeyBackspace, tea.KeyDelete)
+		}
+		if len(m.lines) != 1 || m.lines[0] != "" {
+			t.Fatalf("key %v: expected one empty line, got %q",
+				key, m.lines)
+		}
+
+		// Recover from lines that were emptied behind the model's
+		// back.
+		m.lines = nil
+		m.cursorRow, m.cursorCol = 3, 5
+		pressKeys(m, key)
+		if len(m.lines) != 1 || m.cursorRow != 0 {
+			t.Fatalf("key %v: expected one line and cursor at "+
+				"row 0, got %q and row %d", key, m.lines,
+				m.cursorRow)
+		}
+	}
+}
+
+// TestKeepTrailingBlankLines tests that consecutive trailing blank lines are
+// collapsed by default, but persist if KeepTrailingBlankLines is set.
+func TestKeepTrailingBlankLines(t *testing.T) {
+	for _, keep := range []bool{false, true} {
+		m := NewPromptModel(PromptConfig{
+			KeepTrailingBlankLines: keep,
+		})
+		typeText(m, "SELECT 1\n\n\n")
+
+		want := 2
+		if keep {
+			want = 4
+		}
+		if len(m.lines) != want {
+			t.Fatalf("keep %v: expected %d lines, got %q", keep,
+				want, m.lines)
+		}
+		if m.cursorRow != want-1 {
+			t.Fatalf("keep %v: expected cursor on the last line, "+
+				"got row %d", keep, m.cursorRow)
+		}
+	}
+}
+
+// TestCtrlD tests that Ctrl+D signals end-of-input on an empty buffer, either
+// by quitting or by calling OnEOF, and deletes forward otherwise.
+func TestCtrlD(t *testing.T) {
+	m := NewPromptModel(PromptConfig{})
+	_, cmd := m.Update(tea.KeyMsg{Type: tea.KeyCtrlD})
+	if cmd == nil {
+		t.Fatalf("expected a quit command on an empty buffer")
+	}
+	if _, ok := cmd().(tea.QuitMsg); !ok {
+		t.Fatalf("expected a quit command")
+	}
+
+	eofs := 0
+	m = NewPromptModel(PromptConfig{
+		OnEOF: func() tea.Cmd {
+			eofs++
+			return nil
+		},
+	})
+	pressKeys(m, tea.KeyCtrlD)
+	if eofs != 1 {
+		t.Fatalf("expected OnEOF to be called once, got %d", eofs)
+	}
+
+	typeText(m, "abc")
+	pressKeys(m, tea.KeyLeft, tea.KeyLeft, tea.KeyCtrlD)
+	if got := m.getCurrentInput(); got != "ac" {
+		t.Fatalf("expected forward delete to leave \"ac\", got %q",
+			got)
+	}
+	if eofs != 1 || m.cursorCol != 1 {
+		t.Fatalf("expected no EOF and cursor at 1, got %d and %d",
+			eofs, m.cursorCol)
+	}
+}
+
+// TestPopupState tests that PopupState reports the selection, scroll offset
+// and number of suggestions after navigating the list.
+func TestPopupState(t *testing.T) {
+	m := NewPromptModel(PromptConfig{
+		AutoCompleteFn: numberedCompleter(10),
+		PopupMaxHeight: 4,
+	})
+	if selected, offset, total := m.PopupState(); selected != 0 ||
+		offset != 0 || total != 0 {
+
+		t.Fatalf("expected empty state, got %d/%d/%d", selected,
+			offset, total)
+	}
+
+	typeText(m, "a")
+	for range 5 {
+		pressKeys(m, tea.KeyDown)
+	}
+
+	selected, offset, total := m.PopupState()
+	if selected != 5 || offset != 2 || total != 10 {
+		t.Fatalf("expected 5/2/10, got %d/%d/%d", selected, offset,
+			total)
+	}
+
+	pressKeys(m, tea.KeyUp, tea.KeyUp, tea.KeyUp, tea.KeyUp)
+	selected, offset, _ = m.PopupState()
+	if selected != 1 || offset != 1 {
+		t.Fatalf("expected 1/1, got %d/%d", selected, offset)
+	}
+}
+
+// TestDescriptionBelow tests that descriptions rendered below their
+// suggestions take an indented line of their own.
+func TestDescriptionBelow(t *testing.T) {
+	styles := DefaultPromptStyles()
+	styles.PopupBox = lipgloss.NewStyle()
+	m := NewPromptModel(PromptConfig{
+		AutoCompleteFn: func(_, _ string) []Suggestion {
+			return []Suggestion{
+				{Text: "select", Description: "query rows"},
+				{Text: "sum", Description: "add values"},
+			}
+		},
+		ShowDescription:  true,
+		DescriptionBelow: true,
+		Styles:           styles,
+	})
+	typeText(m, "s")
+
+	lines := strings.Split(m.renderPopup(), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("expected 4 lines, got %q", lines)
+	}
+	for i, want := range []string{"select", "sum"} {
+		word := strings.TrimSpace(lines[2*i])
+		desc := lines[2*i+1]
+		if word != want || !strings.HasPrefix(desc, "  ") ||
+			strings.TrimSpace(desc) == "" {
+
+			t.Fatalf("unexpected item %d: %q, %q", i, word, desc)
+		}
+	}
+}
+
+// TestPopupHeightWithWrappedDescriptions tests that navigating a list whose
+// descriptions wrap onto several lines, with or without group separators,
+// never renders more than PopupMaxHeight rows and keeps the selection
+// visible.
+func TestPopupHeightWithWrappedDescriptions(t *testing.T) {
+	const maxHeight = 5
+
+	// Alternate between short and wrapped descriptions in groups of
+	// varying size.
+	suggestions := make([]Suggestion, 20)
+	for i := range suggestions {
+		desc := "short"
+		if i%3 == 0 {
+			desc = "longer text that wraps"
+		}
+		suggestions[i] = Suggestion{
+			Text:        fmt.Sprintf("item%02d", i),
+			Description: desc,
+			Group:       fmt.Sprintf("group%d", i/4),
+		}
+	}
+
+	for _, below := range []bool{false, true} {
+		styles := DefaultPromptStyles()
+		styles.PopupBox = lipgloss.NewStyle()
+		m := NewPromptModel(PromptConfig{
+			AutoCompleteFn: func(_, _ string) []Suggestion {
+				return suggestions
+			},
+			PopupMaxHeight:      maxHeight,
+			ShowDescription:     true,
+			DescriptionMaxWidth: 10,
+			DescriptionBelow:    below,
+			Styles:              styles,
+		})
+		typeText(m, "i")
+
+		keys := []tea.KeyType{tea.KeyPgDown, tea.KeyPgDown, tea.KeyUp}
+		for range len(suggestions) + 1 {
+			keys = append(keys, tea.KeyDown)
+		}
+		keys = append(keys, tea.KeyUp, tea.KeyPgUp, tea.KeyPgUp)
+		for range len(suggestions) {
+			keys = append(keys, tea.KeyPgDown)
+		}
+
+		for i, key := range keys {
+			pressKeys(m, key)
+
+			rows := lipgloss.Height(m.renderPopup())
+			if rows > maxHeight {
+				t.Fatalf("below %v, step %d: %d rows exceed "+
+					"%d", below, i, rows, maxHeight)
+			}
+
+			selected, offset, _ := m.PopupState()
+			if selected < offset ||
+				selected >= offset+m.popupPageSize(offset) {
+
+				t.Fatalf("below %v, step %d: selection %d "+
+					"not visible from %d", below, i,
+					selected, offset)
+			}
+			if !strings.Contains(m.renderPopup(),
+				suggestions[selected].Text) {
+
+				t.Fatalf("below %v, step %d: selection %d "+
+					"not rendered", below, i, selected)
+			}
+		}
+	}
+}
+
+// TestSetPrompts tests that prompts changed at runtime are used by the next
+// View.
+func TestSetPrompts(t *testing.T) {
+	m := NewPromptModel(PromptConfig{
+		PromptPrimary:   "db1> ",
+		PromptSecondary: "...> ",
+	})
+	typeText(m, "SELECT\n1")
+	if view := m.View(); !strings.Contains(view, "db1> SELECT") ||
+		!strings.Contains(view, "...> 1") {
+
+		t.Fatalf("expected initial prompts, got %q", view)
+	}
+
+	m.SetPrompts("db2> ", "   > ")
+	view := m.View()
+	if !strings.Contains(view, "db2> SELECT") ||
+		!strings.Contains(view, "   > 1") {
+
+		t.Fatalf("expected new prompts, got %q", view)
+	}
+	if strings.Contains(view, "db1>") || strings.Contains(view, "...>") {
+		t.Fatalf("expected old prompts to be gone, got %q", view)
+	}
+}
+
+// TestImmediateCommands tests that listed commands are executed on Enter
+// without a terminator, while other incomplete input isn't.
+func TestImmediateCommands(t *testing.T) {
+	var executed []string
+	m := NewPromptModel(PromptConfig{
+		ExecuteFn: func(input string) string {
+			executed = append(executed, input)
+			return ""
+		},
+		ImmediateCommands: map[string]bool{".quit": true},
+	})
+
+	typeText(m, "  .quit \n")
+	if len(executed) != 1 || executed[0] != "  .quit " {
+		t.Fatalf("expected .quit to be executed, got %q", executed)
+	}
+
+	typeText(m, ".help\n")
+	if len(executed) != 1 {
+		t.Fatalf("expected .help not to be executed, got %q",
+			executed)
+	}
+	if len(m.lines) != 2 || m.lines[0] != ".help" {
+		t.Fatalf("expected a newline to be inserted, got %q", m.lines)
+	}
+}
+
+// TestPreExecuteFn tests that aliases are expanded before execution and that
+// the history stores either the typed or the expanded input.
+func TestPreExecuteFn(t *testing.T) {
+	for _, transformed := range []bool{false, true} {
+		var executed []string
+		m := NewPromptModel(PromptConfig{
+			ExecuteFn: func(input string) string {
+				executed = append(executed, input)
+				return ""
+			},
+			PreExecuteFn: func(input string) string {
+				return strings.ReplaceAll(
+					input, "ls", "SELECT * FROM tables",
+				)
+			},
+			HistoryStoresTransformed: transformed,
+		})
+		typeText(m, "ls;\n")
+
+		want := "SELECT * FROM tables;"
+		if len(executed) != 1 || executed[0] != want {
+			t.Fatalf("expected %q to be executed, got %q", want,
+				executed)
+		}
+
+		wantHistory := "ls;"
+		if transformed {
+			wantHistory = want
+		}
+		if got := m.history.At(0); got != wantHistory {
+			t.Fatalf("transformed %v: expected history %q, got %q",
+				transformed, wantHistory, got)
+		}
+	}
+}
+
+// TestStash tests stashing a multi-line input, editing and submitting other
+// input and restoring the stashed one afterwards.
+func TestStash(t *testing.T) {
+	m := NewPromptModel(PromptConfig{
+		ExecuteFn: func(string) string {
+			return ""
+		},
+	})
+	typeText(m, "SELECT a,\n  b")
+	pressKeys(m, tea.KeyLeft)
+
+	m.Stash()
+	if m.getCurrentInput() != "" || m.cursorRow != 0 ||
+		m.cursorCol != 0 {
+
+		t.Fatalf("expected stash to clear the input")
+	}
+
+	typeText(m, "SELECT 1;\n")
+	typeText(m, "other")
+
+	m.Unstash()
+	if got := m.getCurrentInput(); got != "SELECT a,\n  b" {
+		t.Fatalf("expected stashed input, got %q", got)
+	}
+	if m.cursorRow != 1 || m.cursorCol != 2 {
+		t.Fatalf("expected cursor 1:2, got %d:%d", m.cursorRow,
+			m.cursorCol)
+	}
+
+	// The stash is consumed by restoring it.
+	typeText(m, "x")
+	m.Unstash()
+	if got := m.getCurrentInput(); got != "SELECT a,\n  xb" {
+		t.Fatalf("expected second Unstash to be a no-op, got %q", got)
+	}
+}
+
+// TestTrailingNewlineAfterOutput tests that a blank line separates the output
+// from the prompt only if TrailingNewlineAfterOutput is set.
+func TestTrailingNewlineAfterOutput(t *testing.T) {
+	for _, blank := range []bool{false, true} {
+		m := NewPromptModel(PromptConfig{
+			PromptPrimary: "> ",
+			ExecuteFn: func(string) string {
+				return "result"
+			},
+			TrailingNewlineAfterOutput: blank,
+		})
+		typeText(m, "SELECT 1;\n")
+
+		separator := "-----------------\n> "
+		if blank {
+			separator = "-----------------\n\n> "
+		}
+		if view := m.View(); !strings.Contains(view, separator) {
+			t.Fatalf("blank %v: expected %q in %q", blank,
+				separator, view)
+		}
+	}
+}
+
+// TestSoftWrapUpArrow tests that with SoftWrap, Up first moves through the
+// visual rows of a wrapped first line before navigating the history.
+func TestSoftWrapUpArrow(t *testing.T) {
+	for _, softWrap := range []bool{false, true} {
+		m := NewPromptModel(PromptConfig{
+			PromptPrimary: "> ",
+			SoftWrap:      softWrap,
+		})
+		m.history.Append("SELECT 1;")
+		m.Update(tea.WindowSizeMsg{Width: 12})
+
+		// The line wraps after ten runes next to the prompt.
+		typeText(m, "0123456789abcdefghij")
+		for range 5 {
+			pressKeys(m, tea.KeyLeft)
+		}
+
+		pressKeys(m, tea.KeyUp)
+		if !softWrap {
+			if got := m.getCurrentInput(); got != "SELECT 1;" {
+				t.Fatalf("expected history entry, got %q", got)
+			}
+
+			continue
+		}
+
+		if m.cursorRow != 0 || m.cursorCol != 5 {
+			t.Fatalf("expected cursor 0:5 on the first visual "+
+				"row, got %d:%d", m.cursorRow, m.cursorCol)
+		}
+		if m.historyIndex != -1 {
+			t.Fatalf("expected no history navigation yet")
+		}
+
+		pressKeys(m, tea.KeyUp)
+		if got := m.getCurrentInput(); got != "SELECT 1;" {
+			t.Fatalf("expected history entry, got %q", got)
+		}
+	}
+}
+
+// TestCtrlHomeEnd tests that Ctrl+Home and Ctrl+End jump to the start and end
+// of a multi-line input and hide the suggestions.
+func TestCtrlHomeEnd(t *testing.T) {
+	m := NewPromptModel(PromptConfig{
+		AutoCompleteFn: func(_, word string) []Suggestion {
+			if !strings.HasPrefix("from", word) {
+				return nil
+			}
+
+			return []Suggestion{{Text: "from"}}
+		},
+	})
+	typeText(m, "SELECT a\nFROM tbl\nWHERE x")
+	pressKeys(m, tea.KeyUp, tea.KeyEnd)
+
+	typeText(m, " f")
+	if !m.showPopup {
+		t.Fatalf("expected suggestions to be shown")
+	}
+
+	pressKeys(m, tea.KeyCtrlHome)
+	if m.cursorRow != 0 || m.cursorCol != 0 || m.showPopup {
+		t.Fatalf("expected cursor 0:0 without popup, got %d:%d",
+			m.cursorRow, m.cursorCol)
+	}
+
+	pressKeys(m, tea.KeyCtrlEnd)
+	if m.cursorRow != 2 || m.cursorCol != 7 || m.showPopup {
+		t.Fatalf("expected cursor 2:7 without popup, got %d:%d",
+			m.cursorRow, m.cursorCol)
+	}
+}
+
+// TestOutputMaxHeight tests that output taller than OutputMaxHeight is clipped
+// to a window that can be scrolled with Shift+Up and Shift+Down.
+func TestOutputMaxHeight(t *testing.T) {
+	m := NewPromptModel(PromptConfig{
+		ExecuteFn: func(string) string {
+			lines := make([]string, 10)
+			for i := range lines {
+				lines[i] = fmt.Sprintf("row%d", i)
+			}
+
+			return strings.Join(lines, "\n")
+		},
+		OutputMaxHeight: 3,
+	})
+	typeText(m, "SELECT 1;\n")
+
+	all := m.outputLines()
+	if len(all) <= 3 {
+		t.Fatalf("expected more than 3 output lines, got %q", all)
+	}
+
+	window := func() []string {
+		return strings.Split(m.renderOutput(), "\n")
+	}
+	if got := window(); len(got) != 3 || got[0] != all[0] {
+		t.Fatalf("expected the first 3 lines, got %q", got)
+	}
+
+	pressKeys(m, tea.KeyShiftDown, tea.KeyShiftDown)
+	if got := window(); len(got) != 3 || got[0] != all[2] {
+		t.Fatalf("expected the window to scroll by 2, got %q", got)
+	}
+
+	// Scrolling stops at the end of the output.
+	for range len(all) {
+		pressKeys(m, tea.KeyShiftDown)
+	}
+	if got := window(); got[2] != all[len(all)-1] {
+		t.Fatalf("expected the last lines, got %q", got)
+	}
+
+	pressKeys(m, tea.KeyShiftUp)
+	if got := window(); got[2] != all[len(all)-2] {
+		t.Fatalf("expected the window to scroll up, got %q", got)
+	}
+}
+
+// TestColoredOutputWidth tests that ANSI escape sequences in the output don't
+// count towards its width when truncating or wrapping it.
+func TestColoredOutputWidth(t *testing.T) {
+	const (
+		text    = "abcdefghijkl"
+		colored = "\x1b[31m" + text + "\x1b[0m"
+	)
+
+	tests := []struct {
+		name  string
+		cfg   PromptConfig
+		lines []string
+	}{{
+		name:  "truncate",
+		cfg:   PromptConfig{TruncateOutput: true},
+		lines: []string{"abcdefg…"},
+	}, {
+		name:  "wrap",
+		cfg:   PromptConfig{WrapOutput: true},
+		lines: []string{"abcdefgh", "ijkl"},
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.cfg.ExecuteFn = func(string) string {
+				return colored
+			}
+			m := NewPromptModel(test.cfg)
+			m.Update(tea.WindowSizeMsg{Width: 8})
+			typeText(m, "SELECT 1;\n")
+
+			output := m.renderOutput()
+			if !strings.Contains(output, "\x1b[31m") {
+				t.Fatalf("expected colors to be preserved: %q",
+					output)
+			}
+
+			// Collect the lines of the colored text, skipping the
+			// output's frame.
+			var got []string
+			for _, line := range strings.Split(output, "\n") {
+				plain := ansi.Strip(line)
+				part := strings.TrimSuffix(plain, "…")
+				if part == "" || !strings.Contains(text, part) {
+
+					continue
+				}
+
+				if width := ansi.StringWidth(line); width > 8 {
+					t.Fatalf("line %q is %d wide", line,
+						width)
+				}
+				got = append(got, plain)
+			}
+			if strings.Join(got, "|") !=
+				strings.Join(test.lines, "|") {
+
+				t.Fatalf("expected %q, got %q", test.lines, got)
+			}
+		})
+	}
+}
+
+// TestTerminator tests completing input with a configured terminator, which
+// must be on its own line for "/" or if TerminatorOnOwnLine is set, and that
+// a custom IsCompleteFn isn't replaced by the terminator check.
+func TestTerminator(t *testing.T) {
+	tests := []struct {
+		name     string
+		cfg      PromptConfig
+		input    string
+		complete bool
+	}{{
+		name:     "slash on own line",
+		cfg:      PromptConfig{Terminator: "/"},
+		input:    "BEGIN\n  NULL;\nEND;\n /  \n\n",
+		complete: true,
+	}, {
+		name:  "slash as division",
+		cfg:   PromptConfig{Terminator: "/"},
+		input: "SELECT 4 /",
+	}, {
+		name:     "go at line end",
+		cfg:      PromptConfig{Terminator: "go"},
+		input:    "SELECT 1 go",
+		complete: true,
+	}, {
+		name: "go not on own line",
+		cfg: PromptConfig{
+			Terminator: "go", TerminatorOnOwnLine: true,
+		},
+		input: "SELECT 1 go",
+	}, {
+		name: "go on own line",
+		cfg: PromptConfig{
+			Terminator: "go", TerminatorOnOwnLine: true,
+		},
+		input:    "SELECT 1\ngo",
+		complete: true,
+	}, {
+		name:  "semicolon with terminator",
+		cfg:   PromptConfig{Terminator: "/"},
+		input: "SELECT 1;",
+	}, {
+		name: "custom check kept",
+		cfg: PromptConfig{
+			Terminator: "/",
+			IsCompleteFn: func(input string) bool {
+				return strings.HasSuffix(input, "!")
+			},
+		},
+		input:    "SELECT 1!",
+		complete: true,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			m := NewPromptModel(test.cfg)
+			got := m.config.IsCompleteFn(test.input)
+			if got != test.complete {
+				t.Fatalf("expected complete %v, got %v",
+					test.complete, got)
+			}
+		})
+	}
+
+	// Entering just the terminator doesn't submit anything.
+	executed := 0
+	m := NewPromptModel(PromptConfig{
+		Terminator: "/",
+		ExecuteFn: func(string) string {
+			executed++
+			return ""
+		},
+	})
+	typeText(m, "/\n")
+	if executed != 0 {
+		t.Fatalf("expected a lone terminator not to be executed")
+	}
+
+	// The terminator also applies to configs created by NewPromptConfig,
+	// which still set the default semicolon check.
+	cfg := NewPromptConfig("> ", "| ", nil, nil)
+	if cfg.IsCompleteFn == nil || !cfg.IsCompleteFn("x;") {
+		t.Fatalf("expected NewPromptConfig to set DefaultIsComplete")
+	}
+	cfg.Terminator = "/"
+	if !NewPromptModel(cfg).config.IsCompleteFn("x\n/") {
+		t.Fatalf("expected NewPromptConfig to use the terminator")
+	}
+}
+
+// TestDefaultIsCompleteBalanced tests the bracket and quote balance check with
+// balanced and unbalanced inputs, including quoted brackets.
+func TestDefaultIsCompleteBalanced(t *testing.T) {
+	tests := []struct {
+		input    string
+		complete bool
+	}{
+		{input: "SELECT 1", complete: true},
+		{input: "f(a, [b], {c: (d)})", complete: true},
+		{input: "f(a, [b]", complete: false},
+		{input: "f(a])", complete: false},
+		{input: ")(", complete: false},
+		{input: "f(\n  a\n)", complete: true},
+		{input: "SELECT '('", complete: true},
+		{input: `SELECT "[" || ']'`, complete: true},
+		{input: "SELECT ')", complete: false},
+		{input: `SELECT "it's"`, complete: true},
+		{input: `SELECT 'it\'s'`, complete: true},
+		{input: `SELECT 'a\'`, complete: false},
+		{input: "SELECT \"open", complete: false},
+	}
+
+	for _, test := range tests {
+		got := DefaultIsCompleteBalanced(test.input)
+		if got != test.complete {
+			t.Fatalf("%q: expected %v, got %v", test.input,
+				test.complete, got)
+		}
+	}
+
+	// Balanced input is submitted on Enter, but blank input isn't,
+	// although it is trivially balanced.
+	var executed []string
+	m := NewPromptModel(PromptConfig{
+		IsCompleteFn: DefaultIsCompleteBalanced,
+		ExecuteFn: func(input string) string {
+			executed = append(executed, input)
+			return ""
+		},
+	})
+	typeText(m, "  \n")
+	if len(executed) != 0 || strings.TrimSpace(m.getCurrentInput()) != "" {
+		t.Fatalf("expected blank input not to be executed, got %q",
+			executed)
+	}
+
+	m = NewPromptModel(m.config)
+	typeText(m, "f(\n1)\n")
+	if len(executed) != 1 || executed[0] != "f(\n1)" {
+		t.Fatalf("expected \"f(\\n1)\" to be executed, got %q",
+			executed)
+	}
+
+	// Whether just a terminator is complete is up to the check, which
+	// accepts it here, unlike DefaultIsComplete.
+	typeText(m, ";\n")
+	if len(executed) != 2 || executed[1] != ";" {
+		t.Fatalf("expected \";\" to be executed, got %q", executed)
+	}
+	if DefaultIsComplete(" ; ") {
+		t.Fatalf("expected just a semicolon to be incomplete")
+	}
+}
+
+// TestOnUnhandledKey tests that keys the prompt doesn't handle reach the
+// OnUnhandledKey callback along with its command, while handled keys don't.
+func TestOnUnhandledKey(t *testing.T) {
+	var keys []tea.KeyType
+	m := NewPromptModel(PromptConfig{
+		OnUnhandledKey: func(msg tea.KeyMsg) tea.Cmd {
+			keys = append(keys, msg.Type)
+			return tea.Quit
+		},
+	})
+
+	typeText(m, "a")
+	pressKeys(m, tea.KeyLeft)
+	if len(keys) != 0 {
+		t.Fatalf("expected handled keys not to reach the callback")
+	}
+
+	_, cmd := m.handleKeyPress(tea.KeyMsg{Type: tea.KeyF5})
+	if len(keys) != 1 || keys[0] != tea.KeyF5 {
+		t.Fatalf("expected F5 to reach the callback, got %v", keys)
+	}
+	if cmd == nil {
+		t.Fatalf("expected the callback's command to be returned")
+	}
+	if got := m.getCurrentInput(); got != "a" {
+		t.Fatalf("expected input to be unchanged, got %q", got)
+	}
+}
+
+// prefixCompleter returns an AutoCompleteFunc suggesting the given words that
+// start with the word fragment.
+func prefixCompleter(words ...string) AutoCompleteFunc {
+	return func(_, fragment string) []Suggestion {
+		var suggs []Suggestion
+		for _, word := range words {
+			if strings.HasPrefix(word, fragment) {
+				suggs = append(suggs, Suggestion{Text: word})
+			}
+		}
+
+		return suggs
+	}
+}
+
+// TestManualCompleteOnly tests that typing doesn't open the popup if
+// ManualCompleteOnly is set, but updates a popup opened with Ctrl+Space.
+func TestManualCompleteOnly(t *testing.T) {
+	m := NewPromptModel(PromptConfig{
+		AutoCompleteFn:     prefixCompleter("select", "sum", "set"),
+		ManualCompleteOnly: true,
+	})
+
+	typeText(m, "s")
+	if m.showPopup {
+		t.Fatalf("expected typing not to open the popup")
+	}
+
+	pressKeys(m, tea.KeyCtrlAt)
+	if !m.showPopup || len(m.suggestions) != 3 {
+		t.Fatalf("expected Ctrl+Space to open the popup")
+	}
+
+	typeText(m, "e")
+	got := strings.Join(suggestionTexts(m), "|")
+	if !m.showPopup || got != "select|set" {
+		t.Fatalf("expected typing to update the popup, got %q", got)
+	}
+}
+
+// TestApplyAutocompleteOutOfRange tests that accepting a suggestion with an
+// out-of-range selection clamps it and that an empty list is a no-op.
+func TestApplyAutocompleteOutOfRange(t *testing.T) {
+	m := NewPromptModel(PromptConfig{
+		AutoCompleteFn: prefixCompleter("select", "sum"),
+	})
+	typeText(m, "s")
+
+	m.selectedSuggestionIndex = 5
+	m.applyAutocomplete()
+	if got := m.getCurrentInput(); got != "sum" {
+		t.Fatalf("expected the last suggestion, got %q", got)
+	}
+
+	typeText(m, " s")
+	m.selectedSuggestionIndex = -3
+	m.applyAutocomplete()
+	if got := m.getCurrentInput(); got != "sum select" {
+		t.Fatalf("expected the first suggestion, got %q", got)
+	}
+
+	typeText(m, " s")
+	m.suggestions = nil
+	m.selectedSuggestionIndex = 1
+	m.applyAutocomplete()
+	if got := m.getCurrentInput(); got != "sum select s" {
+		t.Fatalf("expected no change, got %q", got)
+	}
+}
+
+// TestShowTiming tests that the execution time is appended to the output of
+// synchronous and asynchronous executions only if ShowTiming is set.
+func TestShowTiming(t *testing.T) {
+	for _, timing := range []bool{false, true} {
+		m := NewPromptModel(PromptConfig{
+			ExecuteFn: func(string) string {
+				return "sync"
+			},
+			ShowTiming: timing,
+		})
+		typeText(m, "SELECT 1;\n")
+		if got := strings.Contains(m.lastOutput, "(took "); got !=
+			timing {
+
+			t.Fatalf("timing %v: unexpected sync output %q",
+				timing, m.lastOutput)
+		}
+
+		m = NewPromptModel(PromptConfig{
+			ExecuteAsyncFn: func(string) tea.Cmd {
+				return func() tea.Msg {
+					return OutputMsg("async")
+				}
+			},
+			ShowTiming: timing,
+		})
+		typeText(m, "SELECT 1;\n")
+		m.Update(OutputMsg("async"))
+		if got := strings.Contains(m.lastOutput, "(took "); got !=
+			timing {
+
+			t.Fatalf("timing %v: unexpected async output %q",
+				timing, m.lastOutput)
+		}
+	}
+}
+
+// TestSetEnabled tests that input is ignored and rendered without a cursor
+// while the prompt is disabled, except for quitting.
+func TestSetEnabled(t *testing.T) {
+	m := NewPromptModel(PromptConfig{PromptPrimary: "> "})
+	typeText(m, "ab")
+
+	m.SetEnabled(false)
+	typeText(m, "cd")
+	pressKeys(m, tea.KeyBackspace, tea.KeyLeft, tea.KeyEnter)
+	if got := m.getCurrentInput(); got != "ab" || m.cursorCol != 2 {
+		t.Fatalf("expected input to be ignored, got %q at %d", got,
+			m.cursorCol)
+	}
+	if view := m.View(); !strings.Contains(view, "> ab") {
+		t.Fatalf("expected dimmed input without cursor, got %q", view)
+	}
+
+	_, cmd := m.handleKeyPress(tea.KeyMsg{Type: tea.KeyCtrlC})
+	if cmd == nil {
+		t.Fatalf("expected Ctrl+C to quit while disabled")
+	}
+
+	m.SetEnabled(true)
+	typeText(m, "c")
+	if got := m.getCurrentInput(); got != "abc" {
+		t.Fatalf("expected input after enabling, got %q", got)
+	}
+}
+
+// TestBlurredCursor tests that the cursor is rendered with the BlurredCursor
+// style while the prompt is blurred and with the Cursor style otherwise.
+func TestBlurredCursor(t *testing.T) {
+	profile := lipgloss.ColorProfile()
+	lipgloss.SetColorProfile(termenv.TrueColor)
+	defer lipgloss.SetColorProfile(profile)
+
+	styles := DefaultPromptStyles()
+	styles.Cursor = lipgloss.NewStyle().Background(lipgloss.Color("1"))
+	styles.BlurredCursor = lipgloss.NewStyle().Background(
+		lipgloss.Color("2"),
+	)
+	m := NewPromptModel(PromptConfig{Styles: styles})
+	typeText(m, "ab")
+
+	focused := styles.Cursor.Render(" ")
+	blurred := styles.BlurredCursor.Render(" ")
+	if focused == blurred {
+		t.Fatalf("expected distinct cursor styles")
+	}
+
+	if !m.Focused() || !strings.Contains(m.View(), focused) {
+		t.Fatalf("expected the focused cursor, got %q", m.View())
+	}
+
+	m.Blur()
+	if m.Focused() || !strings.Contains(m.View(), blurred) ||
+		strings.Contains(m.View(), focused) {
+
+		t.Fatalf("expected the blurred cursor, got %q", m.View())
+	}
+
+	m.Focus()
+	if !m.Focused() || !strings.Contains(m.View(), focused) {
+		t.Fatalf("expected the focused cursor again, got %q",
+			m.View())
+	}
+}
+
+// TestLineNumbersMultilineOnly tests that with LineNumbersMultilineOnly, a
+// single-line buffer has no gutter but a two-line one does.
+func TestLineNumbersMultilineOnly(t *testing.T) {
+	m := NewPromptModel(PromptConfig{
+		PromptPrimary:            "> ",
+		PromptSecondary:          ". ",
+		ShowLineNumbers:          true,
+		LineNumbersMultilineOnly: true,
+	})
+	typeText(m, "a")
+	if view := m.View(); !strings.HasPrefix(view, "> a") {
+		t.Fatalf("expected no gutter, got %q", view)
+	}
+
+	typeText(m, "\nb")
+	view := m.View()
+	if !strings.HasPrefix(view, "1 > a") ||
+		!strings.Contains(view, "\n2 . b") {
+
+		t.Fatalf("expected a gutter on both lines, got %q", view)
+	}
+
+	// Without the restriction, single lines are numbered too.
+	m.config.LineNumbersMultilineOnly = false
+	pressKeys(m, tea.KeyBackspace, tea.KeyBackspace)
+	if view := m.View(); !strings.HasPrefix(view, "1 > a") {
+		t.Fatalf("expected a gutter, got %q", view)
+	}
+}
+
+// popupLines returns the rendered popup lines without the popup box style.
+func popupLines(m *PromptModel) []string {
+	m.config.Styles.PopupBox = lipgloss.NewStyle()
+	return strings.Split(m.renderPopup(), "\n")
+}
+
+// TestSelectedPrefix tests that the SelectedPrefix marks only the selected
+// suggestion and that unselected suggestions are padded to stay aligned.
+func TestSelectedPrefix(t *testing.T) {
+	m := NewPromptModel(PromptConfig{
+		AutoCompleteFn: prefixCompleter("select", "set", "sum"),
+		SelectedPrefix: "> ",
+	})
+	typeText(m, "s")
+	pressKeys(m, tea.KeyDown)
+
+	lines := popupLines(m)
+	want := []string{"  select", "> set", "  sum"}
+	for i, line := range lines {
+		if !strings.HasPrefix(line, want[i]) {
+			t.Fatalf("line %d: expected prefix %q, got %q", i,
+				want[i], line)
+		}
+	}
+}
+
+// TestGroupSeparators tests that a separator is rendered between suggestions
+// of different groups and that navigation skips over it.
+func TestGroupSeparators(t *testing.T) {
+	m := NewPromptModel(PromptConfig{
+		AutoCompleteFn: func(_, _ string) []Suggestion {
+			return []Suggestion{
+				{Text: "select", Group: "keywords"},
+				{Text: "set", Group: "keywords"},
+				{Text: "sum", Group: "functions"},
+			}
+		},
+	})
+	typeText(m, "s")
+
+	lines := popupLines(m)
+	if len(lines) != 4 || !strings.HasPrefix(lines[2], "─") {
+		t.Fatalf("expected a separator before the third item, got %q",
+			lines)
+	}
+
+	pressKeys(m, tea.KeyDown, tea.KeyDown)
+	if m.selectedSuggestionIndex != 2 {
+		t.Fatalf("expected to select the third item, got %d",
+			m.selectedSuggestionIndex)
+	}
+	pressKeys(m, tea.KeyTab)
+	if got := m.getCurrentInput(); got != "sum" {
+		t.Fatalf("expected the third item to be accepted, got %q", got)
+	}
+}
+
+// TestValueWithSep tests joining the input lines with a custom separator,
+// just like Value does with "\n".
+func TestValueWithSep(t *testing.T) {
+	m := NewPromptModel(PromptConfig{})
+	typeText(m, "SELECT 1\nFROM t\n")
+
+	if got := m.ValueWithSep("\r\n"); got != "SELECT 1\r\nFROM t" {
+		t.Fatalf("expected CRLF separated input, got %q", got)
+	}
+	if got := m.Value(); got != "SELECT 1\nFROM t" {
+		t.Fatalf("expected newline separated input, got %q", got)
+	}
+}
+
+// TestEventFn tests that typing, completing, submitting and recalling history
+// emit the matching events, and that a history entry is read only once per
+// recall.
+func TestEventFn(t *testing.T) {
+	var events []string
+	store := &fakeHistory{}
+	m := NewPromptModel(PromptConfig{
+		AutoCompleteFn: prefixCompleter("select"),
+		ExecuteFn: func(string) string {
+			return ""
+		},
+		History:           store,
+		OnHistoryNavigate: func(string, int) {},
+		EventFn: func(ev Event) {
+			events = append(events, fmt.Sprintf("%d:%s", ev.Kind,
+				ev.Text))
+		},
+	})
+
+	typeText(m, "se")
+	pressKeys(m, tea.KeyTab)
+	typeText(m, ";\n")
+
+	want := []string{
+		fmt.Sprintf("%d:s", EventEdit),
+		fmt.Sprintf("%d:se", EventEdit),
+		fmt.Sprintf("%d:select", EventComplete),
+		fmt.Sprintf("%d:select;", EventEdit),
+		fmt.Sprintf("%d:select;", EventSubmit),
+	}
+	if strings.Join(events, "|") != strings.Join(want, "|") {
+		t.Fatalf("expected events %q, got %q", want, events)
+	}
+
+	events = nil
+	reads := store.reads
+	pressKeys(m, tea.KeyUp)
+	want = []string{fmt.Sprintf("%d:select;", EventHistoryNavigate)}
+	if strings.Join(events, "|") != strings.Join(want, "|") {
+		t.Fatalf("expected events %q, got %q", want, events)
+	}
+	if store.reads-reads != 1 {
+		t.Fatalf("expected one history read, got %d",
+			store.reads-reads)
+	}
+}
+
+// TestIgnoreEmptyEnter tests that Enter on an empty buffer keeps a single
+// empty line if IgnoreEmptyEnter is set and inserts a newline otherwise.
+func TestIgnoreEmptyEnter(t *testing.T) {
+	for _, ignore := range []bool{false, true} {
+		m := NewPromptModel(PromptConfig{
+			IgnoreEmptyEnter: ignore,
+			// Keep the inserted blank lines to count them.
+			KeepTrailingBlankLines: true,
+		})
+		pressKeys(m, tea.KeyEnter, tea.KeyEnter)
+
+		want := 3
+		if ignore {
+			want = 1
+		}
+		if len(m.lines) != want {
+			t.Fatalf("ignore %v: expected %d lines, got %q",
+				ignore, want, m.lines)
+		}
+	}
+}
+
+// TestRightAlignRTL tests that lines with predominantly right-to-left content
+// are right-aligned within the render width, leaving room for the cursor,
+// while other lines stay left-aligned.
+func TestRightAlignRTL(t *testing.T) {
+	m := NewPromptModel(PromptConfig{
+		PromptPrimary:   "> ",
+		PromptSecondary: "> ",
+		RightAlignRTL:   true,
+	})
+	m.Update(tea.WindowSizeMsg{Width: 20})
+	m.lines = []string{"שלום עולם", "abc"}
+	m.cursorRow, m.cursorCol = 1, 3
+
+	lines := strings.Split(m.View(), "\n")
+	rtl := "> " + strings.Repeat(" ", 20-2-9-1) + "שלום עולם"
+	if lines[0] != rtl {
+		t.Fatalf("expected right-aligned line %q, got %q", rtl,
+			lines[0])
+	}
+	if !strings.HasPrefix(lines[1], "> abc") {
+		t.Fatalf("expected left-aligned line, got %q", lines[1])
+	}
+
+	// Without the option, right-to-left lines aren't aligned.
+	m.config.RightAlignRTL = false
+	line := strings.Split(m.View(), "\n")[0]
+	if line != "> שלום עולם" {
+		t.Fatalf("expected left-aligned line, got %q", line)
+	}
+}
+
+// TestMaxWidth tests that MaxWidth limits the width used for truncation and
+// soft wrapping, independent of a wider terminal.
+func TestMaxWidth(t *testing.T) {
+	m := NewPromptModel(PromptConfig{
+		PromptPrimary:  "> ",
+		MaxWidth:       20,
+		TruncateOutput: true,
+		SoftWrap:       true,
+		ExecuteFn: func(string) string {
+			return strings.Repeat("x", 50)
+		},
+	})
+	m.Update(tea.WindowSizeMsg{Width: 80})
+
+	typeText(m, "SELECT 1;\n")
+	for _, line := range strings.Split(m.renderOutput(), "\n") {
+		if width := ansi.StringWidth(line); width > 20 {
+			t.Fatalf("output line %q is %d wide", line, width)
+		}
+	}
+
+	// The input wraps after 18 runes next to the prompt.
+	typeText(m, strings.Repeat("a", 30))
+	if starts := m.visualRowStarts(0); len(starts) != 2 ||
+		starts[1] != 18 {
+
+		t.Fatalf("expected a wrap after 18 runes, got %v", starts)
+	}
+	for _, line := range strings.Split(m.View(), "\n") {
+		if width := ansi.StringWidth(line); width > 20 {
+			t.Fatalf("view line %q is %d wide", line, width)
+		}
+	}
+
+	// A narrower terminal still takes precedence.
+	m.Update(tea.WindowSizeMsg{Width: 12})
+	if starts := m.visualRowStarts(0); len(starts) != 3 {
+		t.Fatalf("expected wraps within 12 columns, got %v", starts)
+	}
+}
+
+// TestSuggestionsOnEmpty tests that the seeded suggestions are shown by a new
+// prompt, when focusing an empty prompt and after submitting, and that typing
+// switches to the completer's results.
+func TestSuggestionsOnEmpty(t *testing.T) {
+	m := NewPromptModel(PromptConfig{
+		AutoCompleteFn: prefixCompleter("select", "set"),
+		ExecuteFn: func(string) string {
+			return ""
+		},
+		SuggestionsOnEmpty: []Suggestion{
+			{Text: ".help"}, {Text: ".tables"},
+		},
+	})
+
+	seeded := func() bool {
+		return m.showPopup &&
+			strings.Join(suggestionTexts(m), "|") == ".help|.tables"
+	}
+	if !seeded() {
+		t.Fatalf("expected a new prompt to show the seeded " +
+			"suggestions")
+	}
+
+	m.Blur()
+	if m.showPopup {
+		t.Fatalf("expected no popup while blurred")
+	}
+	m.Focus()
+	if !seeded() {
+		t.Fatalf("expected focusing to show the seeded suggestions")
+	}
+
+	typeText(m, "se")
+	if got := strings.Join(suggestionTexts(m), "|"); got != "select|set" {
+		t.Fatalf("expected completer results, got %q", got)
+	}
+
+	pressKeys(m, tea.KeyBackspace, tea.KeyBackspace)
+	if !seeded() {
+		t.Fatalf("expected an emptied buffer to show the seeded " +
+			"suggestions")
+	}
+
+	typeText(m, "x;\n")
+	if !seeded() {
+		t.Fatalf("expected the seeded suggestions after submitting")
+	}
+
+	// Up recalls the submitted input instead of navigating the seeded
+	// suggestions.
+	pressKeys(m, tea.KeyUp)
+	if got := m.Value(); got != "x;" || m.showPopup {
+		t.Fatalf("expected the previous entry recalled, got %q", got)
+	}
+
+	// Explicitly requested seeded suggestions are navigated.
+	pressKeys(m, tea.KeyDown, tea.KeyCtrlAt, tea.KeyDown)
+	if !seeded() || m.selectedSuggestionIndex != 1 {
+		t.Fatalf("expected to navigate the requested suggestions")
+	}
+}
+
+// TestKeepPopupOnHorizontalMove tests that moving left inside a word refreshes
+// the suggestions for the shorter fragment if KeepPopupOnHorizontalMove is
+// set and clears them otherwise.
+func TestKeepPopupOnHorizontalMove(t *testing.T) {
+	for _, keep := range []bool{false, true} {
+		cfg := PromptConfig{
+			AutoCompleteFn: prefixCompleter("select", "sum"),
+		}
+		cfg.KeepPopupOnHorizontalMove = keep
+		m := NewPromptModel(cfg)
+		typeText(m, "se")
+		if got := strings.Join(suggestionTexts(m), "|"); got !=
+			"select" {
+
+			t.Fatalf("expected one suggestion, got %q", got)
+		}
+
+		pressKeys(m, tea.KeyLeft)
+		if !keep {
+			if m.showPopup {
+				t.Fatalf("expected the popup to be cleared")
+			}
+
+			continue
+		}
+
+		got := strings.Join(suggestionTexts(m), "|")
+		if !m.showPopup || got != "select|sum" {
+			t.Fatalf("expected suggestions for \"s\", got %q", got)
+		}
+	}
+}
+
+// TestIsEmpty tests IsEmpty with empty, whitespace-only and non-empty
+// buffers.
+func TestIsEmpty(t *testing.T) {
+	tests := []struct {
+		input string
+		empty bool
+	}{
+		{input: "", empty: true},
+		{input: "   ", empty: true},
+		{input: " \n  \n", empty: true},
+		{input: "  a ", empty: false},
+		{input: "\nb", empty: false},
+	}
+
+	for _, test := range tests {
+		m := NewPromptModel(PromptConfig{})
+		typeText(m, test.input)
+		if got := m.IsEmpty(); got != test.empty {
+			t.Fatalf("%q: expected empty %v, got %v", test.input,
+				test.empty, got)
+		}
+	}
+}
+
+// TestMacroKeys tests that a mapped key inserts the output of its function at
+// the cursor, including multi-line output.
+func TestMacroKeys(t *testing.T) {
+	m := NewPromptModel(PromptConfig{
+		MacroKeys: map[string]func() string{
+			"ctrl+t": func() string { return "2024-01-02" },
+			"ctrl+g": func() string { return "a\nb" },
+		},
+	})
+
+	typeText(m, "x")
+	pressKeys(m, tea.KeyLeft, tea.KeyCtrlT)
+	if got := m.getCurrentInput(); got != "2024-01-02x" {
+		t.Fatalf("expected macro output at the cursor, got %q", got)
+	}
+	if m.cursorCol != 10 {
+		t.Fatalf("expected cursor after the output, got %d",
+			m.cursorCol)
+	}
+
+	pressKeys(m, tea.KeyRight, tea.KeyCtrlG)
+	if got := m.getCurrentInput(); got != "2024-01-02xa\nb" {
+		t.Fatalf("expected multi-line macro output, got %q", got)
+	}
+	if m.cursorRow != 1 || m.cursorCol != 1 {
+		t.Fatalf("expected cursor at 1,1, got %d,%d", m.cursorRow,
+			m.cursorCol)
+	}
+}
+
+// TestTabCycles tests that with TabCycles, Tab and Shift+Tab move the
+// selection without modifying the input, Enter accepts it, and Shift+Tab
+// without a popup is passed to OnUnhandledKey.
+func TestTabCycles(t *testing.T) {
+	var unhandled int
+	m := NewPromptModel(PromptConfig{
+		AutoCompleteFn: numberedCompleter(5),
+		TabCycles:      true,
+		OnUnhandledKey: func(tea.KeyMsg) tea.Cmd {
+			unhandled++
+			return nil
+		},
+	})
+
+	typeText(m, "s")
+	if !m.showPopup {
+		t.Fatalf("expected the popup to be shown")
+	}
+
+	pressKeys(m, tea.KeyTab, tea.KeyTab, tea.KeyTab, tea.KeyShiftTab)
+	if m.selectedSuggestionIndex != 2 {
+		t.Fatalf("expected selection 2, got %d",
+			m.selectedSuggestionIndex)
+	}
+	if got := m.getCurrentInput(); got != "s" {
+		t.Fatalf("expected input to be unchanged, got %q", got)
+	}
+	if unhandled != 0 {
+		t.Fatalf("expected Shift+Tab to be handled with the popup")
+	}
+
+	pressKeys(m, tea.KeyEnter)
+	if got := m.getCurrentInput(); got != "s02" {
+		t.Fatalf("expected Enter to accept the selection, got %q", got)
+	}
+
+	m.clearAutocomplete()
+	pressKeys(m, tea.KeyShiftTab)
+	if unhandled != 1 {
+		t.Fatalf("expected Shift+Tab without popup to be unhandled")
+	}
+}
+
+// TestShowSuggestionCount tests that the popup header shows the position of
+// the selected suggestion and the total number of suggestions.
+func TestShowSuggestionCount(t *testing.T) {
+	m := NewPromptModel(PromptConfig{
+		AutoCompleteFn:      numberedCompleter(42),
+		ShowSuggestionCount: true,
+	})
+	typeText(m, "s")
+
+	if got := strings.TrimSpace(popupLines(m)[0]); got != "1/42" {
+		t.Fatalf("expected header %q, got %q", "1/42", got)
+	}
+
+	pressKeys(m, tea.KeyDown, tea.KeyDown)
+	lines := popupLines(m)
+	if got := strings.TrimSpace(lines[0]); got != "3/42" {
+		t.Fatalf("expected header %q, got %q", "3/42", got)
+	}
+	if !strings.Contains(lines[1], "s00") {
+		t.Fatalf("expected suggestions below the header, got %q",
+			lines[1])
+	}
+
+	// Without the option, the popup starts with the suggestions.
+	m.config.ShowSuggestionCount = false
+	if got := popupLines(m)[0]; !strings.Contains(got, "s00") {
+		t.Fatalf("expected no header, got %q", got)
+	}
+}
+
+// TestInsertNewline tests that InsertNewline splits the line at the cursor and
+// moves the cursor to the start of the new line.
+func TestInsertNewline(t *testing.T) {
+	m := NewPromptModel(PromptConfig{})
+	typeText(m, "abcd")
+	pressKeys(m, tea.KeyLeft, tea.KeyLeft)
+
+	m.InsertNewline()
+	if len(m.lines) != 2 {
+		t.Fatalf("expected 2 lines, got %d", len(m.lines))
+	}
+	if got := m.getCurrentInput(); got != "ab\ncd" {
+		t.Fatalf("expected the line to be split, got %q", got)
+	}
+	if m.cursorRow != 1 || m.cursorCol != 0 {
+		t.Fatalf("expected cursor at 1,0, got %d,%d", m.cursorRow,
+			m.cursorCol)
+	}
+}
+
+// TestMaskInput tests that masked input is rendered with the mask rune while
+// the buffer holds the real text, and that it never reaches the completer,
+// the key recording or the history.
+func TestMaskInput(t *testing.T) {
+	var completions int
+	m := NewPromptModel(PromptConfig{
+		AutoCompleteFn: func(_, _ string) []Suggestion {
+			completions++
+			return []Suggestion{{Text: "secret"}}
+		},
+		SuggestionsOnEmpty: []Suggestion{{Text: "secret"}},
+		MaskInput:          true,
+		MaskRune:           '*',
+		RecordKeys:         true,
+	})
+
+	typeText(m, "se cr")
+	m.TriggerComplete()
+	if completions != 0 || m.showPopup {
+		t.Fatalf("expected masked input not to be completed, got %d "+
+			"calls", completions)
+	}
+	if view := m.View(); !strings.Contains(view, "*****") ||
+		strings.Contains(view, "se cr") {
+
+		t.Fatalf("expected masked rendering, got %q", view)
+	}
+	if got := m.Value(); got != "se cr" {
+		t.Fatalf("expected the real text, got %q", got)
+	}
+	if len(m.RecordedKeys()) != 0 {
+		t.Fatalf("expected masked keys not to be recorded")
+	}
+
+	typeText(m, ";\n")
+	if m.history.Len() != 0 {
+		t.Fatalf("expected masked input not to be stored in history")
+	}
+	if m.showPopup {
+		t.Fatalf("expected no suggestions on empty masked input")
+	}
+}
+
+// TestMaskInputEvents tests that masked input never reaches the EventFn or
+// the OnIdle function.
+func TestMaskInputEvents(t *testing.T) {
+	var (
+		events []Event
+		idle   int
+	)
+	m := NewPromptModel(PromptConfig{
+		MaskInput: true,
+		EventFn: func(ev Event) {
+			events = append(events, ev)
+		},
+		OnIdle: func(string) {
+			idle++
+		},
+		IdleTimeout: time.Second,
+	})
+
+	typeText(m, "secret")
+	if cmd := m.scheduleIdleTick(); cmd != nil {
+		t.Fatalf("expected no idle tick for masked input")
+	}
+	m.Update(idleTickMsg{seq: m.keySeq})
+	if idle != 0 {
+		t.Fatalf("expected OnIdle not to be called for masked input")
+	}
+
+	typeText(m, ";\n")
+	kinds := map[EventKind]bool{}
+	for _, ev := range events {
+		if ev.Text != "" {
+			t.Fatalf("expected no event text, got %q", ev.Text)
+		}
+		kinds[ev.Kind] = true
+	}
+	if !kinds[EventEdit] || !kinds[EventSubmit] {
+		t.Fatalf("expected edit and submit events, got %v", events)
+	}
+}
+
+// TestPopupCallbacks tests that OnPopupOpen and OnPopupClose fire exactly when
+// the popup visibility changes.
+func TestPopupCallbacks(t *testing.T) {
+	var opened, closed int
+	m := NewPromptModel(PromptConfig{
+		AutoCompleteFn: prefixCompleter("select", "set"),
+		OnPopupOpen:    func() { opened++ },
+		OnPopupClose:   func() { closed++ },
+	})
+
+	// Narrowing the suggestions keeps the popup open.
+	typeText(m, "se")
+	if opened != 1 || closed != 0 {
+		t.Fatalf("expected 1 open and 0 closes, got %d and %d",
+			opened, closed)
+	}
+
+	// Losing all matches closes it, clearing again doesn't.
+	typeText(m, "x")
+	m.clearAutocomplete()
+	if opened != 1 || closed != 1 {
+		t.Fatalf("expected 1 open and 1 close, got %d and %d",
+			opened, closed)
+	}
+
+	pressKeys(m, tea.KeyBackspace)
+	if opened != 2 || closed != 1 {
+		t.Fatalf("expected 2 opens and 1 close, got %d and %d",
+			opened, closed)
+	}
+}
+
+// TestStripCommentsForComplete tests that trailing line comments are ignored
+// by the completeness check, except inside quotes, and that the comment prefix
+// is configurable.
+func TestStripCommentsForComplete(t *testing.T) {
+	tests := []struct {
+		name   string
+		prefix string
+		input  string
+		want   bool
+	}{
+		{"trailing comment", "", "SELECT 1; -- done", true},
+		{"comment hides terminator", "", "SELECT 1 -- ;", false},
+		{"quoted prefix", "", "SELECT '--'; -- x", true},
+		{"multi-line", "", "SELECT 1; -- a\n-- b", true},
+		{"custom prefix", "#", "SELECT 1; # done", true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			m := NewPromptModel(PromptConfig{
+				StripCommentsForComplete: true,
+				CommentPrefix:            tc.prefix,
+			})
+			got := m.config.IsCompleteFn(tc.input)
+			if got != tc.want {
+				t.Fatalf("expected %v for %q, got %v", tc.want,
+					tc.input, got)
+			}
+		})
+	}
+
+	// Without the option, the comment hides the terminator.
+	m := NewPromptModel(PromptConfig{})
+	if m.config.IsCompleteFn("SELECT 1; -- done") {
+		t.Fatalf("expected comments not to be stripped by default")
+	}
+}
+
+// TestCompleterChain tests that the suggestions of chained completers are
+// concatenated in chain order with duplicates dropped, and that the chain
+// takes precedence over AutoCompleteFn.
+func TestCompleterChain(t *testing.T) {
+	m := NewPromptModel(PromptConfig{
+		AutoCompleteFn: fixedCompleter("unused"),
+		CompleterChain: []AutoCompleteFunc{
+			prefixCompleter("select", "set"),
+			prefixCompleter("sessions", "select", "users"),
+		},
+	})
+	typeText(m, "se")
+
+	got := suggestionTexts(m)
+	want := []string{"select", "set", "sessions"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("expected suggestions %q, got %q", want, got)
+	}
+}
+
+// TestPreferredSuggestion tests that the suggestion marked as Preferred is
+// selected when the popup opens, scrolling it into view if needed.
+func TestPreferredSuggestion(t *testing.T) {
+	preferred := func(idx int) AutoCompleteFunc {
+		return func(_, word string) []Suggestion {
+			suggs := numberedCompleter(20)("", word)
+			suggs[idx].Preferred = true
+			return suggs
+		}
+	}
+
+	m := NewPromptModel(PromptConfig{AutoCompleteFn: preferred(2)})
+	typeText(m, "s")
+	if m.selectedSuggestionIndex != 2 || m.popupScrollOffset != 0 {
+		t.Fatalf("expected selection 2 at offset 0, got %d at %d",
+			m.selectedSuggestionIndex, m.popupScrollOffset)
+	}
+
+	m = NewPromptModel(PromptConfig{
+		AutoCompleteFn: preferred(15),
+		PopupMaxHeight: 5,
+	})
+	typeText(m, "s")
+	if m.selectedSuggestionIndex != 15 {
+		t.Fatalf("expected selection 15, got %d",
+			m.selectedSuggestionIndex)
+	}
+	if m.popupScrollOffset != 11 {
+		t.Fatalf("expected offset 11, got %d", m.popupScrollOffset)
+	}
+
+	// Without a preferred suggestion, the first one is selected.
+	m = NewPromptModel(PromptConfig{AutoCompleteFn: numberedCompleter(5)})
+	typeText(m, "s")
+	if m.selectedSuggestionIndex != 0 {
+		t.Fatalf("expected selection 0, got %d",
+			m.selectedSuggestionIndex)
+	}
+}
+
+// TestEditGraphemeClusters tests that Backspace and Delete remove whole
+// grapheme clusters and keep the cursor column counted in runes.
+func TestEditGraphemeClusters(t *testing.T) {
+	// A thumbs up with a skin tone modifier and an "e" with a combining
+	// acute accent are two runes each.
+	thumbs, accent := "\U0001F44D\U0001F3FD", "é"
+
+	m := NewPromptModel(PromptConfig{})
+	typeText(m, "a"+thumbs+"b"+accent+"c")
+	pressKeys(m, tea.KeyLeft, tea.KeyLeft, tea.KeyLeft, tea.KeyBackspace)
+	if got := m.getCurrentInput(); got != "a"+thumbs+accent+"c" {
+		t.Fatalf("unexpected input after backspace: %q", got)
+	}
+	if m.cursorCol != 3 {
+		t.Fatalf("expected cursor at 3, got %d", m.cursorCol)
+	}
+
+	pressKeys(m, tea.KeyDelete, tea.KeyLeft, tea.KeyLeft,
+		tea.KeyBackspace)
+	if got := m.getCurrentInput(); got != thumbs+"c" {
+		t.Fatalf("unexpected input after delete: %q", got)
+	}
+	if m.cursorCol != 0 {
+		t.Fatalf("expected cursor at 0, got %d", m.cursorCol)
+	}
+}
+
+// BenchmarkKeystrokeLargeBuffer measures typing and deleting a character in
+// the middle of a long line of a buffer with thousands of lines. Like during
+// regular typing, the edits are merged into a single undo group, so that the
+// input is only copied for undo once.
+func BenchmarkKeystrokeLargeBuffer(b *testing.B) {
+	m := NewPromptModel(PromptConfig{UndoCoalesceWindow: time.Minute})
+	m.lines = make([]string, 5000)
+	for i := range m.lines {
+		m.lines[i] = strings.Repeat("SELECT col FROM tbl ", 20)
+	}
+	m.cursorRow = len(m.lines) / 2
+	m.cursorCol = 200
+
+	insert := tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'x'}}
+	backspace := tea.KeyMsg{Type: tea.KeyBackspace}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		m.Update(insert)
+		m.Update(backspace)
+	}
+}
+
+// TestStablePopupWidthFollowsSuggestions tests that the stable popup width is
+// measured again whenever the suggestions change, e.g., while typing narrows
+// them down.
+func TestStablePopupWidthFollowsSuggestions(t *testing.T) {
+	m := NewPromptModel(PromptConfig{
+		AutoCompleteFn: prefixCompleter(
+			"sa", "sb", "sc", "sdescending_order_by",
+		),
+		PopupMaxHeight:   2,
+		StablePopupWidth: true,
+	})
+
+	typeText(m, "s")
+	wide := lipgloss.Width(m.renderPopup())
+
+	typeText(m, "a")
+	narrow := lipgloss.Width(m.renderPopup())
+	if narrow >= wide {
+		t.Fatalf("expected the width to shrink from %d, got %d", wide,
+			narrow)
+	}
+
+	// The stable width matches measuring all suggestions directly.
+	m.config.StablePopupWidth = false
+	if got := lipgloss.Width(m.renderPopup()); got != narrow {
+		t.Fatalf("expected width %d, got %d", narrow, got)
+	}
+}
+
+// BenchmarkRenderPopupLarge measures rendering the popup for a huge list of
+// suggestions with StablePopupWidth, which must only touch the visible ones.
+func BenchmarkRenderPopupLarge(b *testing.B) {
+	m := NewPromptModel(PromptConfig{
+		AutoCompleteFn:   numberedCompleter(10000),
+		StablePopupWidth: true,
+		ShowDescription:  true,
+	})
+	typeText(m, "s")
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_ = m.renderPopup()
+	}
+}
+
+// TestKeepPopupAfterApply tests that applying a suggestion refreshes the popup
+// for the new context with KeepPopupAfterApply and closes it otherwise.
+func TestKeepPopupAfterApply(t *testing.T) {
+	for _, keep := range []bool{false, true} {
+		var fragments []string
+		complete := prefixCompleter("user", "users", "user_id")
+		m := NewPromptModel(PromptConfig{
+			AutoCompleteFn: func(text, word string) []Suggestion {
+				fragments = append(fragments, word)
+				return complete(text, word)
+			},
+			KeepPopupAfterApply: keep,
+		})
+
+		typeText(m, "us")
+		pressKeys(m, tea.KeyTab)
+		if got := m.getCurrentInput(); got != "user" {
+			t.Fatalf("expected the suggestion applied, got %q", got)
+		}
+		if m.showPopup != keep {
+			t.Fatalf("expected popup shown to be %v", keep)
+		}
+		if !keep {
+			continue
+		}
+
+		last := fragments[len(fragments)-1]
+		if last != "user" {
+			t.Fatalf("expected suggestions for %q, got %q", "user",
+				last)
+		}
+		got := strings.Join(suggestionTexts(m), ",")
+		if got != "user,users,user_id" {
+			t.Fatalf("expected refreshed suggestions, got %q", got)
+		}
+	}
+}
+
+// fuzzPieces holds the text pieces random input is built from, covering
+// multibyte, combining, wide and emoji characters as well as word separators.
+var fuzzPieces = []string{
+	"a", "Z", "_", " ", ".", "(", "\t", "ß", "e\u0301", "日本",
+	"\U0001F44D\U0001F3FD", "\u200d",
+}
+
+// randomText returns random text of up to n pieces from fuzzPieces.
+func randomText(rng *rand.Rand, n int) string {
+	var sb strings.Builder
+	for i := rng.Intn(n + 1); i > 0; i-- {
+		sb.WriteString(fuzzPieces[rng.Intn(len(fuzzPieces))])
+	}
+
+	return sb.String()
+}
+
+// TestUpdateAutocompleteRandomInput feeds random multibyte input with
+// arbitrary, possibly out-of-range cursor positions to updateAutocomplete and
+// asserts that it never panics, leaves the cursor in bounds and passes a word
+// fragment ending at the cursor to the completer.
+func TestUpdateAutocompleteRandomInput(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+
+	var before, word string
+	m := NewPromptModel(PromptConfig{
+		AutoCompleteFn: func(text, fragment string) []Suggestion {
+			before, word = text, fragment
+			return []Suggestion{{Text: fragment + "x"}}
+		},
+	})
+
+	for i := 0; i < 5000; i++ {
+		m.lines = strings.Split(randomText(rng, 3)+"\n"+
+			randomText(rng, 8), "\n")
+		m.cursorRow = rng.Intn(len(m.lines)+4) - 2
+		m.cursorCol = rng.Intn(24) - 4
+		m.lastSuggestedWord = ""
+		before, word = "", ""
+
+		input := strings.Join(m.lines, "\n")
+		m.updateAutocomplete()
+
+		if m.cursorRow < 0 || m.cursorRow >= len(m.lines) ||
+			m.cursorCol < 0 ||
+			m.cursorCol > m.lineLen(m.cursorRow) {
+
+			t.Fatalf("cursor %d:%d out of bounds for %q",
+				m.cursorRow, m.cursorCol, input)
+		}
+		if !strings.HasSuffix(before, word) {
+			t.Fatalf("fragment %q doesn't end at the cursor in %q",
+				word, before)
+		}
+
+		// Applying the suggestion must be safe, too.
+		m.applyAutocomplete()
+	}
+}
+
+// fuzzKeys holds the key presses FuzzKeySequence picks from, covering the
+// editing, navigation, completion, undo, block and multi-cursor keys as well as
+// Vi normal mode commands.
+var fuzzKeys = func() []tea.KeyMsg {
+	keys := []tea.KeyMsg{}
+	for _, typ := range []tea.KeyType{
+		tea.KeyBackspace, tea.KeyDelete, tea.KeyEnter, tea.KeySpace,
+		tea.KeyTab, tea.KeyShiftTab, tea.KeyLeft, tea.KeyRight,
+		tea.KeyUp, tea.KeyDown, tea.KeyPgUp, tea.KeyPgDown,
+		tea.KeyCtrlHome, tea.KeyCtrlEnd, tea.KeyCtrlAt, tea.KeyCtrlB,
+		tea.KeyCtrlD, tea.KeyCtrlZ, tea.KeyCtrlDown, tea.KeyShiftUp,
+		tea.KeyShiftDown, tea.KeyCtrlUnderscore, tea.KeyEsc,
+	} {
+		keys = append(keys, tea.KeyMsg{Type: typ})
+	}
+
+	pieces := append([]string{";", "d", "w", "x", "i", "0", "$"},
+		fuzzPieces...)
+	for _, piece := range pieces {
+		keys = append(keys, tea.KeyMsg{
+			Type: tea.KeyRunes, Runes: []rune(piece),
+		})
+	}
+
+	return keys
+}()
+
+// FuzzKeySequence feeds random sequences of key presses to Update and asserts
+// that it never panics and that the input always has at least one line with
+// the cursor in bounds. The first byte selects the configuration, every
+// following byte a key from fuzzKeys.
+func FuzzKeySequence(f *testing.F) {
+	f.Add([]byte{0, 30, 31, 2, 0, 0, 4, 17})
+	f.Add([]byte{1, 30, 30, 22, 25, 26, 0, 1, 17})
+	f.Add([]byte{6, 32, 2, 30, 14, 8, 8, 15, 30, 1})
+	f.Add([]byte{7, 35, 36, 33, 2, 18, 5, 17, 28, 29})
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if len(data) == 0 {
+			return
+		}
+
+		m := NewPromptModel(PromptConfig{
+			AutoCompleteFn: prefixCompleter("select", "set"),
+			ViMode:         data[0]&1 != 0,
+			TabCycles:      data[0]&2 != 0,
+			SoftWrap:       data[0]&4 != 0,
+		})
+		m.Update(tea.WindowSizeMsg{Width: 12, Height: 10})
+
+		for _, b := range data[1:] {
+			key := fuzzKeys[int(b)%len(fuzzKeys)]
+			m.Update(key)
+
+			if len(m.lines) == 0 {
+				t.Fatalf("no lines left after %v", key)
+			}
+			if m.cursorRow < 0 || m.cursorRow >= len(m.lines) ||
+				m.cursorCol < 0 ||
+				m.cursorCol > m.lineLen(m.cursorRow) {
+
+				t.Fatalf("cursor %d:%d out of bounds for %q "+
+					"after %v", m.cursorRow, m.cursorCol,
+					m.lines, key)
+			}
+		}
+
+		_ = m.View()
+	})
+}
+
+// TestAcceptSuggestion tests that AcceptSuggestion applies the suggestion at
+// the given index and rejects out-of-range indices and a hidden popup.
+func TestAcceptSuggestion(t *testing.T) {
+	m := NewPromptModel(PromptConfig{
+		AutoCompleteFn: fixedCompleter("sum", "set", "select"),
+	})
+
+	typeText(m, "x s")
+	for _, index := range []int{-1, 3} {
+		if m.AcceptSuggestion(index) {
+			t.Fatalf("expected index %d to be rejected", index)
+		}
+	}
+	if got := m.getCurrentInput(); got != "x s" {
+		t.Fatalf("expected input to be unchanged, got %q", got)
+	}
+
+	if !m.AcceptSuggestion(2) {
+		t.Fatalf("expected index 2 to be accepted")
+	}
+	if got := m.getCurrentInput(); got != "x select" {
+		t.Fatalf("expected the suggestion applied, got %q", got)
+	}
+	if m.showPopup || m.AcceptSuggestion(0) {
+		t.Fatalf("expected no suggestion to accept without popup")
+	}
+}
+
+// TestBusyPrompt tests that the busy prompt replaces the primary prompt
+// between an asynchronous submit and the arrival of its output, including
+// streamed output.
+func TestBusyPrompt(t *testing.T) {
+	m := NewPromptModel(PromptConfig{
+		PromptPrimary: "db> ",
+		BusyPrompt:    "... ",
+		ExecuteAsyncFn: func(string) tea.Cmd {
+			return nil
+		},
+	})
+
+	// prompt returns the prompt of the input line, which follows any
+	// output.
+	prompt := func() string {
+		lines := strings.Split(m.View(), "\n")
+		return lines[len(lines)-1][:4]
+	}
+
+	if got := prompt(); got != "db> " {
+		t.Fatalf("expected the primary prompt, got %q", got)
+	}
+
+	typeText(m, "SELECT 1;\n")
+	if got := prompt(); got != "... " {
+		t.Fatalf("expected the busy prompt, got %q", got)
+	}
+
+	m.Update(OutputMsg("1"))
+	if got := prompt(); got != "db> " {
+		t.Fatalf("expected the primary prompt again, got %q", got)
+	}
+
+	typeText(m, "SELECT 2;\n")
+	m.Update(OutputChunkMsg("2"))
+	if got := prompt(); got != "... " {
+		t.Fatalf("expected the busy prompt while streaming, got %q",
+			got)
+	}
+
+	m.Update(OutputDoneMsg{})
+	if got := prompt(); got != "db> " {
+		t.Fatalf("expected the primary prompt again, got %q", got)
+	}
+}
+
+// TestWrapOutput tests that WrapOutput word wraps long output lines to the
+// available width, breaking long words and preserving embedded newlines.
+func TestWrapOutput(t *testing.T) {
+	output := "the quick brown fox\nabcdefghijkl\n日本語のテキスト"
+
+	tests := []struct {
+		name  string
+		wrap  bool
+		width int
+		want  []string
+	}{{
+		name:  "disabled",
+		width: 10,
+		want:  strings.Split(output, "\n"),
+	}, {
+		name:  "wrapped",
+		wrap:  true,
+		width: 10,
+		want: []string{
+			"the quick", "brown fox", "abcdefghij", "kl",
+			"日本語のテ", "キスト",
+		},
+	}, {
+		name: "unknown width",
+		wrap: true,
+		want: strings.Split(output, "\n"),
+	}}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			m := NewPromptModel(PromptConfig{WrapOutput: tc.wrap})
+			m.Update(tea.WindowSizeMsg{Width: tc.width})
+			m.setOutput(output)
+
+			got := m.outputLines()
+			for i := range got {
+				got[i] = strings.TrimRight(got[i], " ")
+			}
+			want := strings.Join(tc.want, "|")
+			if strings.Join(got, "|") != want {
+				t.Fatalf("expected %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+// TestWordUnderCursor tests that WordUnderCursor expands both left and right
+// from the cursor, and that it leaves an out-of-bounds cursor untouched.
+func TestWordUnderCursor(t *testing.T) {
+	tests := []struct {
+		name string
+		line string
+		col  int
+		want string
+	}{
+		{"middle", "SELECT name FROM", 9, "name"},
+		{"word start", "SELECT name FROM", 7, "name"},
+		{"word end", "SELECT name FROM", 11, "name"},
+		{"between spaces", "a  b", 2, ""},
+		{"multibyte", "x größe y", 4, "größe"},
+		{"beyond line end", "abc", 10, "abc"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			m := NewPromptModel(PromptConfig{})
+			m.lines = []string{tc.line}
+			m.cursorCol = tc.col
+
+			if got := m.WordUnderCursor(); got != tc.want {
+				t.Fatalf("expected %q, got %q", tc.want, got)
+			}
+			if m.cursorCol != tc.col {
+				t.Fatalf("expected the cursor to stay, got %d",
+					m.cursorCol)
+			}
+		})
+	}
+}
+
+// TestCollapseBlankLines tests that interior blank lines are removed from the
+// executed and stored input with CollapseBlankLines and kept by default.
+func TestCollapseBlankLines(t *testing.T) {
+	lines := []string{"SELECT 1", "", "  ", "FROM t", "", "WHERE x;"}
+
+	tests := []struct {
+		name     string
+		collapse bool
+		want     string
+	}{
+		{"default", false, "SELECT 1\n\n  \nFROM t\n\nWHERE x;"},
+		{"collapsed", true, "SELECT 1\nFROM t\nWHERE x;"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var executed string
+			m := NewPromptModel(PromptConfig{
+				CollapseBlankLines: tc.collapse,
+				ExecuteFn: func(input string) string {
+					executed = input
+					return ""
+				},
+			})
+			m.lines = append([]string{}, lines...)
+			m.Submit()
+
+			if executed != tc.want {
+				t.Fatalf("expected %q executed, got %q",
+					tc.want, executed)
+			}
+			if got := m.history.At(0); got != tc.want {
+				t.Fatalf("expected %q stored, got %q", tc.want,
+					got)
+			}
+		})
+	}
+}
+
+// TestPromptWidth tests the prompt widths of the first and the following rows,
+// with and without aligning the secondary prompt, including wide runes.
+func TestPromptWidth(t *testing.T) {
+	tests := []struct {
+		name          string
+		primary       string
+		secondary     string
+		align         bool
+		first, second int
+	}{
+		{"plain", "db> ", "-> ", false, 4, 3},
+		{"aligned", "mydb> ", "-> ", true, 6, 6},
+		{"wide runes", "日本> ", "> ", false, 6, 2},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			m := NewPromptModel(PromptConfig{
+				PromptPrimary:        tc.primary,
+				PromptSecondary:      tc.secondary,
+				AlignSecondaryPrompt: tc.align,
+			})
+
+			if got := m.PromptWidth(0); got != tc.first {
+				t.Fatalf("expected width %d for row 0, got %d",
+					tc.first, got)
+			}
+			if got := m.PromptWidth(1); got != tc.second {
+				t.Fatalf("expected width %d for row 1, got %d",
+					tc.second, got)
+			}
+		})
+	}
+}
+
+// TestEOLCursorChar tests that the configured glyph is rendered as the cursor
+// past the end of the line only, and that a space is used by default.
+func TestEOLCursorChar(t *testing.T) {
+	m := NewPromptModel(PromptConfig{EOLCursorChar: "▉"})
+	typeText(m, "abc")
+
+	if got := ansi.Strip(m.renderInputLine(0)); got != "abc▉" {
+		t.Fatalf("expected the glyph at the end of line, got %q", got)
+	}
+
+	pressKeys(m, tea.KeyLeft)
+	if got := ansi.Strip(m.renderInputLine(0)); got != "abc" {
+		t.Fatalf("expected no glyph over text, got %q", got)
+	}
+
+	m = NewPromptModel(PromptConfig{})
+	typeText(m, "abc")
+	if got := ansi.Strip(m.renderInputLine(0)); got != "abc " {
+		t.Fatalf("expected a space cursor by default, got %q", got)
+	}
+}
+
+// TestInlineSuggestions tests that the remainder of the selected suggestion is
+// rendered as ghost text instead of the popup, that Tab accepts it and that no
+// ghost text is shown for masked input.
+func TestInlineSuggestions(t *testing.T) {
+	m := NewPromptModel(PromptConfig{
+		AutoCompleteFn:    prefixCompleter("select", "set"),
+		InlineSuggestions: true,
+	})
+	typeText(m, "se")
+
+	if got := ansi.Strip(m.renderInputLine(0)); got != "select" {
+		t.Fatalf("expected ghost text after the input, got %q", got)
+	}
+	if strings.Contains(m.View(), "set") {
+		t.Fatalf("expected no popup, got %q", m.View())
+	}
+
+	// Down selects another suggestion for the ghost text.
+	pressKeys(m, tea.KeyDown)
+	if got := m.ghostText(); got != "t" {
+		t.Fatalf("expected ghost text %q, got %q", "t", got)
+	}
+
+	pressKeys(m, tea.KeyTab)
+	if got := m.getCurrentInput(); got != "set" {
+		t.Fatalf("expected Tab to accept the suggestion, got %q", got)
+	}
+	if got := m.ghostText(); got != "" {
+		t.Fatalf("expected no ghost text after accepting, got %q", got)
+	}
+
+	// Masked input never shows ghost text, even with suggestions set.
+	m.config.MaskInput = true
+	m.lines, m.cursorCol = []string{"se"}, 2
+	m.lastSuggestedWord = "se"
+	m.setSuggestions([]Suggestion{{Text: "select"}})
+	m.setPopupVisible(true)
+	if got := m.ghostText(); got != "" {
+		t.Fatalf("expected no ghost text for masked input, got %q",
+			got)
+	}
+}
+
+// TestSuggestionMaxWidth tests that long suggestions are rendered truncated
+// with an ellipsis but inserted in full when accepted.
+func TestSuggestionMaxWidth(t *testing.T) {
+	m := NewPromptModel(PromptConfig{
+		AutoCompleteFn:     fixedCompleter("customer_addresses", "cu"),
+		SuggestionMaxWidth: 8,
+	})
+	typeText(m, "cu")
+
+	lines := popupLines(m)
+	if got := strings.TrimSpace(lines[0]); got != "custome…" {
+		t.Fatalf("expected a truncated suggestion, got %q", got)
+	}
+	if got := strings.TrimSpace(lines[1]); got != "cu" {
+		t.Fatalf("expected a short suggestion unchanged, got %q", got)
+	}
+
+	pressKeys(m, tea.KeyTab)
+	if got := m.getCurrentInput(); got != "customer_addresses" {
+		t.Fatalf("expected the full suggestion inserted, got %q", got)
+	}
+}
+
+// TestSoleExactMatchHidesPopup tests that the popup is hidden when the only
+// suggestion equals the typed word, ignoring case only if configured.
+func TestSoleExactMatchHidesPopup(t *testing.T) {
+	tests := []struct {
+		name       string
+		typed      string
+		ignoreCase bool
+		want       bool
+	}{
+		{"partial word", "sel", false, true},
+		{"full keyword", "select", false, false},
+		{"other case", "SELECT", false, true},
+		{"other case ignored", "SELECT", true, false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			m := NewPromptModel(PromptConfig{
+				AutoCompleteFn:       fixedCompleter("select"),
+				ExactMatchIgnoreCase: tc.ignoreCase,
+			})
+			typeText(m, tc.typed)
+
+			if m.showPopup != tc.want {
+				t.Fatalf("expected popup shown to be %v",
+					tc.want)
+			}
+		})
+	}
+
+	// An exact match among other suggestions keeps the popup.
+	m := NewPromptModel(PromptConfig{
+		AutoCompleteFn: fixedCompleter("select", "selection"),
+	})
+	typeText(m, "select")
+	if !m.showPopup {
+		t.Fatalf("expected the popup with several suggestions")
+	}
+}
+
+// TestPopupRowCount tests the number of popup rows for suggestion lists
+// shorter and longer than PopupMaxHeight, with descriptions below the
+// suggestions and with the popup hidden.
+func TestPopupRowCount(t *testing.T) {
+	described := func(_, word string) []Suggestion {
+		suggs := numberedCompleter(3)("", word)
+		for i := range suggs {
+			suggs[i].Description = "desc"
+		}
+		return suggs
+	}
+
+	tests := []struct {
+		name      string
+		complete  AutoCompleteFunc
+		descBelow bool
+		want      int
+	}{
+		{"short list", numberedCompleter(3), false, 3},
+		{"long list", numberedCompleter(20), false, 5},
+		{"descriptions below", described, true, 4},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := PromptConfig{
+				AutoCompleteFn:   tc.complete,
+				PopupMaxHeight:   5,
+				ShowDescription:  true,
+				DescriptionBelow: tc.descBelow,
+			}
+			cfg.Styles = DefaultPromptStyles()
+			cfg.Styles.PopupBox = lipgloss.NewStyle()
+			m := NewPromptModel(cfg)
+			typeText(m, "s")
+
+			if got := m.PopupRowCount(); got != tc.want {
+				t.Fatalf("expected %d rows, got %d", tc.want,
+					got)
+			}
+
+			// A popup box border adds a row above and below.
+			m.config.Styles.PopupBox = lipgloss.NewStyle().
+				Border(lipgloss.NormalBorder())
+			if got := m.PopupRowCount(); got != tc.want+2 {
+				t.Fatalf("expected %d rows with border, got %d",
+					tc.want+2, got)
+			}
+
+			m.clearAutocomplete()
+			if got := m.PopupRowCount(); got != 0 {
+				t.Fatalf("expected no rows when hidden, got %d",
+					got)
+			}
+		})
+	}
+}
+
+// TestPopupRowCountMatchesRendering tests that the computed number of popup
+// rows matches the rendered popup with wrapped descriptions, group separators
+// and the count header while scrolling through the suggestions.
+func TestPopupRowCountMatchesRendering(t *testing.T) {
+	complete := func(_, word string) []Suggestion {
+		suggs := numberedCompleter(12)("", word)
+		for i := range suggs {
+			suggs[i].Group = fmt.Sprint(i / 4)
+			if i%3 == 0 {
+				suggs[i].Description = "a long description " +
+					"that wraps"
+			}
+		}
+		return suggs
+	}
+
+	for _, below := range []bool{false, true} {
+		m := NewPromptModel(PromptConfig{
+			AutoCompleteFn:      complete,
+			PopupMaxHeight:      7,
+			ShowDescription:     true,
+			DescriptionBelow:    below,
+			DescriptionMaxWidth: 8,
+			ShowSuggestionCount: true,
+		})
+		typeText(m, "s")
+
+		for i := 0; i < 12; i++ {
+			want := lipgloss.Height(m.renderPopup())
+			if got := m.PopupRowCount(); got != want {
+				t.Fatalf("below %v, step %d: expected %d "+
+					"rows, got %d", below, i, want, got)
+			}
+			pressKeys(m, tea.KeyDown)
+		}
+	}
+}
+
+// TestSubmitKeep tests that SubmitKeep executes and stores the input but keeps
+// it for editing with the cursor at its end, and that the output is set.
+func TestSubmitKeep(t *testing.T) {
+	m := NewPromptModel(PromptConfig{
+		ExecuteFn: func(input string) string {
+			return "ran " + input
+		},
+	})
+	typeText(m, "SELECT *\nFROM t")
+	pressKeys(m, tea.KeyUp)
+
+	m.SubmitKeep()
+	if got := m.getCurrentInput(); got != "SELECT *\nFROM t" {
+		t.Fatalf("expected the input kept, got %q", got)
+	}
+	if m.cursorRow != 1 || m.cursorCol != 6 {
+		t.Fatalf("expected cursor at the end, got %d,%d", m.cursorRow,
+			m.cursorCol)
+	}
+	if !strings.Contains(m.lastOutput, "ran SELECT *\nFROM t") {
+		t.Fatalf("expected the output set, got %q", m.lastOutput)
+	}
+	if m.history.Len() != 1 || m.history.At(0) != "SELECT *\nFROM t" {
+		t.Fatalf("expected the input stored in history")
+	}
+
+	// Blank input isn't submitted.
+	m = NewPromptModel(PromptConfig{})
+	typeText(m, "  ")
+	m.SubmitKeep()
+	if m.history.Len() != 0 {
+		t.Fatalf("expected blank input not to be submitted")
+	}
+}
+
+// TestMultiLinePrompt tests that all lines of a multi-line primary prompt are
+// rendered, and that the aligned secondary prompt follows the width of the
+// last line of the primary or the busy prompt.
+func TestMultiLinePrompt(t *testing.T) {
+	m := NewPromptModel(PromptConfig{
+		PromptPrimary:        "[db]\nsql> ",
+		PromptSecondary:      "> ",
+		BusyPrompt:           "running\n... ",
+		AlignSecondaryPrompt: true,
+		ExecuteAsyncFn: func(string) tea.Cmd {
+			return nil
+		},
+	})
+	typeText(m, "a\nb")
+
+	view := ansi.Strip(m.View())
+	if !strings.HasPrefix(view, "[db]\nsql> a\n   > b") {
+		t.Fatalf("expected all prompt lines, got %q", view)
+	}
+	if got := m.PromptWidth(1); got != 5 {
+		t.Fatalf("expected secondary width 5, got %d", got)
+	}
+
+	// While busy, the secondary prompt aligns to the busy prompt.
+	typeText(m, ";\nc\nd")
+	view = ansi.Strip(m.View())
+	if !strings.Contains(view, "running\n... c\n  > d") {
+		t.Fatalf("expected the busy prompt lines, got %q", view)
+	}
+	if got := m.PromptWidth(1); got != 4 {
+		t.Fatalf("expected secondary width 4, got %d", got)
+	}
+}
+
+// TestMaxSuggestions tests that a large list of suggestions is capped to the
+// first MaxSuggestions for display and navigation, and that values <= 0 mean
+// no limit.
+func TestMaxSuggestions(t *testing.T) {
+	for _, limit := range []int{0, -1, 10} {
+		m := NewPromptModel(PromptConfig{
+			AutoCompleteFn: numberedCompleter(5000),
+			MaxSuggestions: limit,
+		})
+		typeText(m, "s")
+
+		want := 5000
+		if limit > 0 {
+			want = limit
+		}
+		if len(m.suggestions) != want {
+			t.Fatalf("expected %d suggestions for limit %d, got %d",
+				want, limit, len(m.suggestions))
+		}
+		if m.suggestions[0].Text != "s00" {
+			t.Fatalf("expected the first suggestions to be kept")
+		}
+	}
+
+	// Navigation wraps around within the capped list.
+	m := NewPromptModel(PromptConfig{
+		AutoCompleteFn: numberedCompleter(5000),
+		MaxSuggestions: 3,
+	})
+	typeText(m, "s")
+	pressKeys(m, tea.KeyUp)
+	if m.selectedSuggestionIndex != 2 {
+		t.Fatalf("expected the last kept suggestion selected, got %d",
+			m.selectedSuggestionIndex)
+	}
+}
+
+// TestToggleDescription tests that Ctrl+/ (sent as Ctrl+_) toggles whether the
+// suggestion descriptions are rendered, without changing the input.
+func TestToggleDescription(t *testing.T) {
+	m := NewPromptModel(PromptConfig{
+		AutoCompleteFn: func(_, _ string) []Suggestion {
+			return []Suggestion{
+				{Text: "select", Description: "query rows"},
+				{Text: "set", Description: "assign"},
+			}
+		},
+	})
+	typeText(m, "s")
+
+	hasDesc := func() bool {
+		return strings.Contains(m.renderPopup(), "query rows")
+	}
+	if hasDesc() {
+		t.Fatalf("expected descriptions hidden by default")
+	}
+
+	pressKeys(m, tea.KeyCtrlUnderscore)
+	if !hasDesc() {
+		t.Fatalf("expected descriptions shown after toggling")
+	}
+
+	pressKeys(m, tea.KeyCtrlUnderscore)
+	if hasDesc() {
+		t.Fatalf("expected descriptions hidden after toggling again")
+	}
+	if got := m.getCurrentInput(); got != "s" || !m.showPopup {
+		t.Fatalf("expected input and popup unchanged, got %q", got)
+	}
+}
+
+// unframedOutput returns the displayed output without the frame added to the
+// output of complete executions and without surrounding whitespace.
+func unframedOutput(m *PromptModel) string {
+	output := strings.TrimSpace(m.lastOutput)
+	output = strings.TrimPrefix(output, "--- Executing ---")
+	output = strings.TrimSuffix(output, "-----------------")
+
+	return strings.TrimSpace(output)
+}
+
+// TestEmptyOutputText tests that the placeholder is rendered for empty
+// synchronous, asynchronous and streamed output, and that nothing is rendered
+// without it.
+func TestEmptyOutputText(t *testing.T) {
+	tests := []struct {
+		name   string
+		cfg    PromptConfig
+		finish func(m *PromptModel)
+	}{{
+		name: "sync",
+		cfg: PromptConfig{
+			ExecuteFn: func(string) string { return " \n" },
+		},
+		finish: func(*PromptModel) {},
+	}, {
+		name: "async",
+		cfg: PromptConfig{
+			ExecuteAsyncFn: func(string) tea.Cmd { return nil },
+		},
+		finish: func(m *PromptModel) {
+			m.Update(OutputMsg(""))
+		},
+	}, {
+		name: "streamed",
+		cfg: PromptConfig{
+			ExecuteAsyncFn: func(string) tea.Cmd { return nil },
+		},
+		finish: func(m *PromptModel) {
+			m.Update(OutputChunkMsg(""))
+			m.Update(OutputDoneMsg{})
+		},
+	}}
+
+	for _, tc := range tests {
+		for _, text := range []string{"", "OK"} {
+			t.Run(tc.name+"/"+text, func(t *testing.T) {
+				cfg := tc.cfg
+				cfg.EmptyOutputText = text
+				m := NewPromptModel(cfg)
+				typeText(m, "SET x = 1;\n")
+				tc.finish(m)
+
+				if got := unframedOutput(m); got != text {
+					t.Fatalf("expected output %q, got %q",
+						text, got)
+				}
+			})
+		}
+	}
+}